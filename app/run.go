@@ -1,5 +1,7 @@
 package app
 
+import "time"
+
 var (
 	TasksComposeFile string
 	DetachMode       bool = false
@@ -7,4 +9,14 @@ var (
 	ShowDetail       bool
 	InitCmdOutput    string
 	InitCmdIsWindows bool
+	JsonOutput       bool
+	LogsFollow       bool
+	LogsTail         int
+	LogsSince        string
+	UpNoDeps         bool
+	UpExclude        []string
+	UpAbortOnExit    bool
+	UpExitCodeFrom   string
+	UpTimeout        time.Duration
+	GraphFormat      string
 )
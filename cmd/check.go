@@ -29,6 +29,13 @@ var CheckCmd = &cobra.Command{
 		if len(config.AppConfig.Tasks) > 0 {
 
 			if app.ShowDetail {
+				if len(config.AppConfig.Variables) > 0 {
+					variables, err := json.MarshalIndent(config.AppConfig.Variables, "", "  ")
+					if err != nil {
+						utils.SharedAppLogger.Fatal(err)
+					}
+					utils.SharedAppLogger.Info("Variables:", string(variables))
+				}
 				utils.SharedAppLogger.Info(fmt.Sprintf("Found %d tasks in config.\n", len(config.AppConfig.Tasks)))
 				jsonData, err := json.MarshalIndent(config.AppConfig.Tasks, "", "  ")
 				if err != nil {
@@ -53,7 +60,21 @@ func init() {
 
 func CheckConfig() error {
 
-	config.InitConfig()
+	if err := LoadConfig(); err != nil {
+		return err
+	}
+
+	return ValidateConfig()
+}
+
+func LoadConfig() error {
+	if err := config.InitConfig(); err != nil {
+		return fmt.Errorf("Error loading config: %v\n", err)
+	}
+	return nil
+}
+
+func ValidateConfig() error {
 
 	if err := config.AppConfig.Validate(); err != nil {
 		return fmt.Errorf("Error validating config: %v\n", err)
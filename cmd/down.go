@@ -1,39 +1,50 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/vulcanshen-tpi/task-compose/app"
+	"github.com/vulcanshen-tpi/task-compose/config"
 	"github.com/vulcanshen-tpi/task-compose/procedure"
 	"github.com/vulcanshen-tpi/task-compose/utils"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
 
 type ShutdownProcess struct {
-	process *os.Process
-	name    string
+	process     *os.Process
+	pgid        int
+	name        string
+	stopSignal  string
+	stopTimeout time.Duration
 }
 
-func (p *ShutdownProcess) kill(wg *sync.WaitGroup) {
-	if err := p.process.Kill(); err != nil {
+func (p *ShutdownProcess) kill() {
+	if spinner, ok := procedure.TaskSpinner.GetSpinner(p.name); ok {
+		spinner.UpdateMessage("Shutting down")
+	}
+	alive := func() bool {
+		return procedure.ProcessAlive(p.process, p.pgid)
+	}
+	if message, err := procedure.StopProcess(p.process, p.pgid, p.stopSignal, p.stopTimeout, alive); err != nil {
 		if spinner, ok := procedure.TaskSpinner.GetSpinner(p.name); ok {
 			spinner.ErrorWithMessagef("Error killing process: %s", err.Error())
 		}
 	} else {
 		if spinner, ok := procedure.TaskSpinner.GetSpinner(p.name); ok {
-			spinner.CompleteWithMessagef("Shutdown Completed PID: %d", p.process.Pid)
+			spinner.CompleteWithMessagef("Shutdown Completed|%s", message)
 		}
 	}
-	wg.Done()
 }
 
 var DownCmd = &cobra.Command{
 	Use:   "down",
-	Short: "Kill previous tasks processes",
-	Long:  "Kill previous tasks processes with command: task-compose down",
+	Short: "Stop previous tasks processes",
+	Long:  "Stop previous tasks processes in reverse dependency order with command: task-compose down",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		app.DetachMode = true
 		procedure.InitializeSpinnerAgent()
@@ -58,13 +69,25 @@ var DownCmd = &cobra.Command{
 				return
 			}
 
-			var shutdownProcess []ShutdownProcess
-
-			var waitGroup = &sync.WaitGroup{}
-			waitGroup.Add(len(pids.Tasks))
+			var shutdownProcess = make(map[string]*ShutdownProcess)
 
 			for _, task := range pids.Tasks {
-				procedure.TaskSpinner.RegisterSpinner(task.Name, task.Name+"|", "Shutting down")
+				procedure.TaskSpinner.RegisterSpinner(task.Name, task.Name+"|", "Waiting for dependents")
+
+				if task.State() == procedure.ProcessStale {
+					if spinner, ok := procedure.TaskSpinner.GetSpinner(task.Name); ok {
+						spinner.ErrorWithMessagef("PID %d belongs to another process, skipped", task.Pid)
+					}
+					continue
+				}
+
+				// the pid and process group of an exited task may already belong to other processes
+				if task.ExitCode != nil {
+					if spinner, ok := procedure.TaskSpinner.GetSpinner(task.Name); ok {
+						spinner.CompleteWithMessagef("Already exited with code %d", *task.ExitCode)
+					}
+					continue
+				}
 
 				process, err := os.FindProcess(task.Pid)
 
@@ -74,15 +97,76 @@ var DownCmd = &cobra.Command{
 					}
 					continue
 				} else {
-					shutdownProcess = append(shutdownProcess, ShutdownProcess{process: process, name: task.Name})
+					shutdownProcess[task.Name] = &ShutdownProcess{
+						process:     process,
+						pgid:        task.Pgid,
+						name:        task.Name,
+						stopSignal:  task.StopSignal,
+						stopTimeout: procedure.ParseStopTimeout(task.StopTimeout),
+					}
 				}
 			}
 
-			for _, process := range shutdownProcess {
-				go process.kill(waitGroup)
-			}
+			var layers = shutdownLayers(shutdownProcess)
 
-			waitGroup.Wait()
+			stopInReverseOrder(layers, func(name string) {
+				shutdownProcess[name].kill()
+			})
+
+			procedure.RemoveTaskProcessLog()
 		}
 	},
 }
+
+func init() {
+	DownCmd.PersistentFlags().StringVarP(&app.TasksComposeFile, "configfile", "f", "", "Specify the path to the configuration file, default is 'task-compose.yaml'")
+}
+
+// shutdownLayers 依照設定檔的 depends_on 將執行中的任務分層，設定檔無法讀取時退回為單一層級
+func shutdownLayers(processes map[string]*ShutdownProcess) [][]string {
+	var layers [][]string
+	if err := CheckConfig(); err != nil {
+		utils.SharedAppLogger.Warn(fmt.Sprintf("%vStopping all tasks at once.", err))
+	} else if startupOrder, err := config.AppConfig.GetLayeredStartupOrder(); err != nil {
+		utils.SharedAppLogger.Warn(fmt.Sprintf("%v, stopping all tasks at once.", err))
+	} else {
+		layers = startupOrder
+	}
+
+	var known = make(map[string]bool)
+	var result [][]string
+	for _, layer := range layers {
+		var running []string
+		for _, name := range layer {
+			known[name] = true
+			if _, ok := processes[name]; ok {
+				running = append(running, name)
+			}
+		}
+		result = append(result, running)
+	}
+
+	// tasks which are no longer in the config have no known dependents, stop them first
+	var unknown []string
+	for name := range processes {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return append(result, unknown)
+}
+
+// stopInReverseOrder 由最後一層開始停止任務，每一層的任務全部結束後才處理下一層
+func stopInReverseOrder(layers [][]string, stop func(name string)) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		var waitGroup = &sync.WaitGroup{}
+		waitGroup.Add(len(layers[i]))
+		for _, name := range layers[i] {
+			go func(name string) {
+				defer waitGroup.Done()
+				stop(name)
+			}(name)
+		}
+		waitGroup.Wait()
+	}
+}
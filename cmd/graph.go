@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/vulcanshen-tpi/task-compose/app"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"io"
+	"strings"
+)
+
+var GraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export the task dependency graph",
+	Long:  "Export the task dependency graph with its startup layers as DOT, Mermaid or plain text with command: task-compose graph --format dot|mermaid|ascii",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// keep the console output limited to the graph
+		app.DetachMode = true
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := CheckConfig(); err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+
+		layers, err := config.AppConfig.GetLayeredStartupOrder()
+		if err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+
+		var out = cmd.OutOrStdout()
+		switch app.GraphFormat {
+		case "dot":
+			writeDotGraph(out, layers)
+		case "mermaid":
+			writeMermaidGraph(out, layers)
+		case "ascii":
+			writeAsciiGraph(out, layers)
+		default:
+			utils.SharedAppLogger.Fatal(fmt.Errorf("unknown graph format %q, expected one of: dot, mermaid, ascii", app.GraphFormat))
+		}
+	},
+}
+
+func init() {
+	GraphCmd.PersistentFlags().StringVar(&app.GraphFormat, "format", "dot", "Output format: dot, mermaid or ascii")
+	GraphCmd.PersistentFlags().StringVarP(&app.TasksComposeFile, "configfile", "f", "", "Specify the path to the configuration file, default is 'task-compose.yaml'")
+}
+
+// writeDotGraph 以 Graphviz DOT 格式輸出，每一個啟動層級為一個 cluster，箭頭由任務指向其依賴
+func writeDotGraph(out io.Writer, layers [][]string) {
+	_, _ = fmt.Fprintln(out, "digraph \"task-compose\" {")
+	_, _ = fmt.Fprintln(out, "  node [shape=box];")
+	for i, layer := range layers {
+		_, _ = fmt.Fprintf(out, "  subgraph cluster_layer_%d {\n", i)
+		_, _ = fmt.Fprintf(out, "    label=\"layer %d\";\n", i)
+		for _, name := range layer {
+			var task = config.AppTasksConfig[name]
+			_, _ = fmt.Fprintf(out, "    %q [label=%q];\n", name, fmt.Sprintf("%s\n(%s)", name, task.Healthcheck.Kind()))
+		}
+		_, _ = fmt.Fprintln(out, "  }")
+	}
+	for _, task := range config.AppConfig.Tasks {
+		for _, dependency := range task.DependsOn {
+			_, _ = fmt.Fprintf(out, "  %q -> %q [label=%q];\n", task.Name, dependency.Name, dependency.Condition)
+		}
+	}
+	_, _ = fmt.Fprintln(out, "}")
+}
+
+// writeMermaidGraph 以 Mermaid flowchart 格式輸出，任務名稱可能含有特殊字元，因此以編號作為節點 id
+func writeMermaidGraph(out io.Writer, layers [][]string) {
+	var ids = make(map[string]string)
+	for i, task := range config.AppConfig.Tasks {
+		ids[task.Name] = fmt.Sprintf("task%d", i)
+	}
+	_, _ = fmt.Fprintln(out, "flowchart TD")
+	for i, layer := range layers {
+		_, _ = fmt.Fprintf(out, "  subgraph layer_%d [\"layer %d\"]\n", i, i)
+		for _, name := range layer {
+			var task = config.AppTasksConfig[name]
+			var label = strings.ReplaceAll(name, "\"", "#quot;")
+			_, _ = fmt.Fprintf(out, "    %s[\"%s<br/>(%s)\"]\n", ids[name], label, task.Healthcheck.Kind())
+		}
+		_, _ = fmt.Fprintln(out, "  end")
+	}
+	for _, task := range config.AppConfig.Tasks {
+		for _, dependency := range task.DependsOn {
+			_, _ = fmt.Fprintf(out, "  %s -->|%s| %s\n", ids[task.Name], dependency.Condition, ids[dependency.Name])
+		}
+	}
+}
+
+func writeAsciiGraph(out io.Writer, layers [][]string) {
+	for i, layer := range layers {
+		_, _ = fmt.Fprintf(out, "layer %d\n", i)
+		for _, name := range layer {
+			var task = config.AppTasksConfig[name]
+			var line = fmt.Sprintf("  %s [%s]", name, task.Healthcheck.Kind())
+			if len(task.DependsOn) > 0 {
+				var dependencies []string
+				for _, dependency := range task.DependsOn {
+					dependencies = append(dependencies, fmt.Sprintf("%s (%s)", dependency.Name, dependency.Condition))
+				}
+				line += " -> " + strings.Join(dependencies, ", ")
+			}
+			_, _ = fmt.Fprintln(out, line)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/vulcanshen-tpi/task-compose/app"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const logsFollowInterval = 250 * time.Millisecond
+
+var logFileNamePattern = regexp.MustCompile(`^(.+)-(\d{4}-\d{2}-\d{2})\.log$`)
+
+// outputFileNamePattern 比對 detach 模式下任務程序的輸出檔案
+var outputFileNamePattern = regexp.MustCompile(`^(.+)-(\d{4}-\d{2}-\d{2})\.output\.log$`)
+
+type logLine struct {
+	task string
+	time time.Time
+	text string
+}
+
+// taskLogFollower 追蹤單一任務目前讀取的 log 檔案與位置，pattern 區分 log 檔案與程序輸出檔案
+type taskLogFollower struct {
+	task    string
+	pattern *regexp.Regexp
+	file    string
+	offset  int64
+	partial string
+	last    time.Time
+}
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs [task...]",
+	Short: "Show the log files of the tasks",
+	Long:  "Show the log files written to the 'logs' directory with command: task-compose logs [task...]",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// keep the console output limited to the task logs
+		app.DetachMode = true
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var since time.Time
+		if app.LogsSince != "" {
+			var err error
+			if since, err = parseSince(app.LogsSince); err != nil {
+				utils.SharedAppLogger.Fatal(err)
+			}
+		}
+
+		var tasks = args
+		if len(tasks) == 0 {
+			tasks = logTaskNames()
+		}
+
+		var out = cmd.OutOrStdout()
+		var lines []logLine
+		var followers []*taskLogFollower
+		for _, task := range tasks {
+			var taskLines []logLine
+			for _, pattern := range []*regexp.Regexp{logFileNamePattern, outputFileNamePattern} {
+				var follower = &taskLogFollower{task: task, pattern: pattern}
+				for _, file := range taskLogFiles(task, pattern, since) {
+					follower.file = file
+					follower.offset = 0
+					follower.partial = ""
+					taskLines = append(taskLines, follower.read()...)
+				}
+				followers = append(followers, follower)
+			}
+			taskLines = filterSince(taskLines, since)
+			sort.SliceStable(taskLines, func(i, j int) bool {
+				return taskLines[i].time.Before(taskLines[j].time)
+			})
+			if app.LogsTail >= 0 && len(taskLines) > app.LogsTail {
+				taskLines = taskLines[len(taskLines)-app.LogsTail:]
+			}
+			lines = append(lines, taskLines...)
+		}
+
+		// interleave the tasks by the time each line was written
+		sort.SliceStable(lines, func(i, j int) bool {
+			return lines[i].time.Before(lines[j].time)
+		})
+		for _, line := range lines {
+			printLogLine(out, line)
+		}
+
+		if !app.LogsFollow {
+			return
+		}
+
+		var interrupt = make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		var ticker = time.NewTicker(logsFollowInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-interrupt:
+				return
+			case <-ticker.C:
+				for _, follower := range followers {
+					for _, line := range follower.follow() {
+						printLogLine(out, line)
+					}
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	LogsCmd.PersistentFlags().BoolVar(&app.LogsFollow, "follow", false, "Follow the log output")
+	LogsCmd.PersistentFlags().IntVar(&app.LogsTail, "tail", -1, "Number of lines to show from the end of the logs of each task")
+	LogsCmd.PersistentFlags().StringVar(&app.LogsSince, "since", "", "Show logs since a timestamp (e.g. 2025-06-01T10:00:00) or a relative duration (e.g. 30m)")
+	LogsCmd.PersistentFlags().StringVarP(&app.TasksComposeFile, "configfile", "f", "", "Specify the path to the configuration file, default is 'task-compose.yaml'")
+}
+
+// logTaskNames 回傳設定檔中的任務，設定檔無法讀取時改由 logs 目錄中的檔案推斷
+func logTaskNames() []string {
+	var names []string
+	if err := CheckConfig(); err == nil {
+		for _, task := range config.AppConfig.Tasks {
+			names = append(names, task.Name)
+		}
+		return names
+	}
+
+	entries, err := os.ReadDir(utils.LogDir)
+	if err != nil {
+		return names
+	}
+	var found = make(map[string]bool)
+	for _, entry := range entries {
+		if match := logFileNamePattern.FindStringSubmatch(entry.Name()); match != nil && !found[match[1]] {
+			found[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// taskLogFiles 依照日期排序回傳任務符合 pattern 的檔案，略過 since 之後沒有再寫入的檔案
+func taskLogFiles(task string, pattern *regexp.Regexp, since time.Time) []string {
+	entries, err := os.ReadDir(utils.LogDir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		var match = pattern.FindStringSubmatch(entry.Name())
+		if match == nil || match[1] != task {
+			continue
+		}
+		// a detached process keeps writing to the output file of the day it started, the date in the name is not enough
+		if info, err := entry.Info(); !since.IsZero() && (err != nil || info.ModTime().Before(since)) {
+			continue
+		}
+		files = append(files, filepath.Join(utils.LogDir, entry.Name()))
+	}
+	// the date layout sorts chronologically
+	sort.Strings(files)
+	return files
+}
+
+func filterSince(lines []logLine, since time.Time) []logLine {
+	if since.IsZero() {
+		return lines
+	}
+	var filtered []logLine
+	for _, line := range lines {
+		if !line.time.Before(since) {
+			filtered = append(filtered, line)
+		}
+	}
+	return filtered
+}
+
+// read 讀取目前檔案中新增的完整行，沒有時間戳記的行沿用前一行的時間。
+// 程序輸出檔案沒有時間戳記，以檔案最後寫入的時間作為每一行的時間
+func (f *taskLogFollower) read() []logLine {
+	file, err := os.Open(f.file)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var output = f.pattern == outputFileNamePattern
+	if output {
+		if info, err := file.Stat(); err == nil {
+			f.last = info.ModTime()
+		}
+	}
+
+	if _, err = file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil
+	}
+
+	var lines []logLine
+	var reader = bufio.NewReader(file)
+	for {
+		chunk, err := reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		if err != nil {
+			// keep an incomplete line until the rest of it is written
+			f.partial += chunk
+			break
+		}
+		var text = strings.TrimRight(f.partial+chunk, "\r\n")
+		f.partial = ""
+		if !output && len(text) > len(utils.LogTimeLayout) {
+			if written, err := time.ParseInLocation(utils.LogTimeLayout, text[:len(utils.LogTimeLayout)], time.Local); err == nil {
+				f.last = written
+				text = strings.TrimPrefix(text[len(utils.LogTimeLayout):], " ")
+			}
+		}
+		lines = append(lines, logLine{task: f.task, time: f.last, text: text})
+	}
+	return lines
+}
+
+// follow 讀取新增的內容，當天的檔案讀完且出現更新日期的檔案時切換過去
+func (f *taskLogFollower) follow() []logLine {
+	var lines []logLine
+	if f.file != "" {
+		lines = f.read()
+	}
+	var files = taskLogFiles(f.task, f.pattern, time.Time{})
+	if len(files) > 0 && files[len(files)-1] > f.file {
+		f.file = files[len(files)-1]
+		f.offset = 0
+		f.partial = ""
+		lines = append(lines, f.read()...)
+	}
+	return lines
+}
+
+func printLogLine(out io.Writer, line logLine) {
+	_, _ = fmt.Fprintf(out, "%s%s\n", utils.ColoredPrefix(line.task, utils.Color.GetColorCode(line.task)), line.text)
+}
+
+// parseSince 支援相對時間 (30m、2h) 與絕對時間
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", utils.LogDateLayout} {
+		if since, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return since, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q, expected a duration (e.g. 30m) or a timestamp (e.g. 2025-06-01T10:00:00)", value)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/vulcanshen-tpi/task-compose/app"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"github.com/vulcanshen-tpi/task-compose/procedure"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type TaskStatus struct {
+	Name     string     `json:"name"`
+	Pid      int        `json:"pid"`
+	State    string     `json:"state"`
+	Uptime   string     `json:"uptime,omitempty"`
+	Health   string     `json:"health"`
+	Restarts int        `json:"restarts"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	ExitedAt *time.Time `json:"exited_at,omitempty"`
+}
+
+const (
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+	healthNone      = "-"
+)
+
+var PsCmd = &cobra.Command{
+	Use:     "ps",
+	Aliases: []string{"status"},
+	Short:   "Show the state of the running tasks",
+	Long:    "Show the state of the tasks recorded by the last launch with command: task-compose ps",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// keep the console output limited to the status table
+		app.DetachMode = true
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := os.Getwd()
+		if err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+
+		pidFile, err := os.ReadFile(filepath.Join(dir, procedure.PidFile))
+		if err != nil && !os.IsNotExist(err) {
+			utils.SharedAppLogger.Fatal(err)
+		}
+		var pids procedure.TaskProcessLog
+		if err = yaml.Unmarshal(pidFile, &pids); err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+
+		var healthchecks = make(map[string]config.TaskConfig)
+		if err = CheckConfig(); err == nil {
+			healthchecks = config.AppTasksConfig
+		}
+
+		var statuses = make([]TaskStatus, 0, len(pids.Tasks))
+		for _, process := range pids.Tasks {
+			var status = TaskStatus{
+				Name:     process.Name,
+				Pid:      process.Pid,
+				State:    process.State(),
+				Health:   healthNone,
+				Restarts: process.Restarts,
+				ExitCode: process.ExitCode,
+				ExitedAt: process.ExitedAt,
+			}
+			if status.State == procedure.ProcessRunning {
+				if !process.StartedAt.IsZero() {
+					status.Uptime = time.Since(process.StartedAt).Round(time.Second).String()
+				}
+				if taskConfig, ok := healthchecks[process.Name]; ok {
+					status.Health = probeHealth(taskConfig)
+				}
+			}
+			statuses = append(statuses, status)
+		}
+
+		if app.JsonOutput {
+			jsonData, err := json.MarshalIndent(statuses, "", "  ")
+			if err != nil {
+				utils.SharedAppLogger.Fatal(err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(jsonData))
+			return
+		}
+
+		var writer = tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tPID\tSTATE\tUPTIME\tHEALTH\tRESTARTS\tLAST EXIT")
+		for _, status := range statuses {
+			var uptime = status.Uptime
+			if uptime == "" {
+				uptime = "-"
+			}
+			var lastExit = "-"
+			if status.ExitCode != nil {
+				lastExit = strconv.Itoa(*status.ExitCode)
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%d\t%s\n",
+				status.Name, status.Pid, status.State, uptime, status.Health, status.Restarts, lastExit)
+		}
+		if err = writer.Flush(); err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	PsCmd.PersistentFlags().BoolVar(&app.JsonOutput, "json", false, "Print the task states as JSON")
+	PsCmd.PersistentFlags().StringVarP(&app.TasksComposeFile, "configfile", "f", "", "Specify the path to the configuration file, default is 'task-compose.yaml'")
+}
+
+func probeHealth(taskConfig config.TaskConfig) string {
+	task, err := procedure.CreateTask(taskConfig)
+	if err != nil {
+		return healthNone
+	}
+	healthy, configured := task.CheckHealth()
+	if !configured {
+		return healthNone
+	}
+	if healthy {
+		return healthHealthy
+	}
+	return healthUnhealthy
+}
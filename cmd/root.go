@@ -29,8 +29,11 @@ func Execute() {
 	RootCmd.AddCommand(DownCmd)
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(InitCmd)
+	RootCmd.AddCommand(PsCmd)
+	RootCmd.AddCommand(LogsCmd)
+	RootCmd.AddCommand(GraphCmd)
 	if len(os.Args) == 1 && app.Portable == "true" {
-		RootCmd.SetArgs([]string{UpCmd.Use})
+		RootCmd.SetArgs([]string{UpCmd.Name()})
 	}
 	if err := RootCmd.Execute(); err != nil {
 		utils.SharedAppLogger.Fatal(err)
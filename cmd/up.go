@@ -1,23 +1,31 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/vulcanshen-tpi/task-compose/app"
 	"github.com/vulcanshen-tpi/task-compose/config"
 	"github.com/vulcanshen-tpi/task-compose/procedure"
 	"github.com/vulcanshen-tpi/task-compose/utils"
+	"io"
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 var AppTasks map[string]*procedure.Task
 var UpCmd = &cobra.Command{
-	Use:   "up",
+	Use:   "up [task...]",
 	Short: "Execute tasks according to the YAML configuration file.",
-	Long:  "Execute tasks according to the YAML configuration file. with command: task-compose up",
+	Long:  "Execute tasks according to the YAML configuration file. with command: task-compose up, or task-compose up [task...] to start the given tasks with their dependencies",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		procedure.InitializeSpinnerAgent()
 		procedure.StartSpinnerAgent()
@@ -26,7 +34,20 @@ var UpCmd = &cobra.Command{
 		procedure.StopSpinnerAgent()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := CheckConfig(); err != nil {
+		if err := LoadConfig(); err != nil {
+			utils.SharedAppLogger.Fatal(err)
+		}
+
+		if len(args) > 0 || len(app.UpExclude) > 0 {
+			// only the selected subgraph has to be valid
+			selected, err := config.AppConfig.Select(args, !app.UpNoDeps, app.UpExclude)
+			if err != nil {
+				utils.SharedAppLogger.Fatal(err)
+			}
+			config.AppConfig = selected
+		}
+
+		if err := ValidateConfig(); err != nil {
 			utils.SharedAppLogger.Fatal(err)
 		}
 
@@ -43,19 +64,117 @@ var UpCmd = &cobra.Command{
 			var task = AppTasks[taskConfig.Name]
 			if len(taskConfig.DependsOn) > 0 {
 				for _, dependency := range taskConfig.DependsOn {
-					task.AppendDependencies(AppTasks[dependency])
+					task.AppendDependencies(AppTasks[dependency.Name], dependency.Condition)
 				}
 			}
 		}
 
+		if app.UpExitCodeFrom != "" && AppTasks[app.UpExitCodeFrom] == nil {
+			utils.SharedAppLogger.Fatal(fmt.Errorf("--exit-code-from: unknown task %s", app.UpExitCodeFrom))
+		}
+		if app.DetachMode && (app.UpAbortOnExit || app.UpExitCodeFrom != "") {
+			utils.SharedAppLogger.Fatal(errors.New("--abort-on-exit and --exit-code-from cannot be used with --detach"))
+		}
+
+		// every task reports once when it is done and will not be restarted
+		var exits = make(chan *procedure.Task, len(AppTasks))
+		for _, task := range AppTasks {
+			task.OnExit = func(task *procedure.Task) {
+				exits <- task
+			}
+		}
+
+		// a nil task reports that the whole stack exceeded --timeout
+		var timeouts = make(chan *procedure.Task, len(AppTasks)+1)
+		var timers []*time.Timer
+		if app.UpTimeout > 0 {
+			timers = append(timers, time.AfterFunc(app.UpTimeout, func() {
+				timeouts <- nil
+			}))
+		}
+		for _, task := range AppTasks {
+			if task.StartTimeout > 0 {
+				timers = append(timers, time.AfterFunc(task.StartTimeout, func() {
+					timeouts <- task
+				}))
+			}
+		}
+
 		var waitGroup = &sync.WaitGroup{}
 		waitGroup.Add(len(AppTasks))
 
+		var interrupt = make(chan os.Signal, 1)
+		if !app.DetachMode {
+			signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		}
+
 		for _, task := range AppTasks {
 			go task.Start(waitGroup)
 		}
 
-		waitGroup.Wait()
+		var done = make(chan struct{})
+		go func() {
+			waitGroup.Wait()
+			close(done)
+		}()
+
+		var exitCode int
+		var aborted bool
+		var timeoutReport strings.Builder
+	wait:
+		for {
+			select {
+			case <-done:
+				exitCode = stackExitCode()
+				break wait
+			case task := <-exits:
+				if !abortOnExit(task) {
+					continue
+				}
+				utils.SharedAppLogger.Warn(fmt.Sprintf("Task %s exited, shutting down tasks", task.Name))
+				aborted = true
+				shutdownTasks(interrupt, done)
+				procedure.RemoveTaskProcessLog()
+				if app.UpExitCodeFrom != "" {
+					task = AppTasks[app.UpExitCodeFrom]
+				}
+				exitCode = taskExitCode(task)
+				break wait
+			case task := <-timeouts:
+				var pending = pendingTasks()
+				if len(pending) == 0 || (task != nil && !slices.Contains(pending, task)) {
+					continue
+				}
+				var reason = fmt.Sprintf("Tasks did not start within %s", app.UpTimeout)
+				if task != nil {
+					reason = fmt.Sprintf("Task %s did not start within %s", task.Name, task.StartTimeout)
+				}
+				utils.SharedAppLogger.Error(fmt.Errorf("%s, shutting down tasks", reason))
+				// the report has to be taken before the shutdown stops the pending tasks
+				printStartupReport(&timeoutReport, reason, pending)
+				shutdownTasks(interrupt, done)
+				procedure.RemoveTaskProcessLog()
+				exitCode = 1
+				break wait
+			case sig := <-interrupt:
+				utils.SharedAppLogger.Warn(fmt.Sprintf("Received %s, shutting down tasks", sig))
+				shutdownTasks(interrupt, done)
+				procedure.RemoveTaskProcessLog()
+				procedure.StopSpinnerAgent()
+				printJobSummary(cmd.OutOrStdout(), true)
+				os.Exit(signalExitCode(sig))
+			}
+		}
+		signal.Stop(interrupt)
+		for _, timer := range timers {
+			timer.Stop()
+		}
+		procedure.StopSpinnerAgent()
+		fmt.Fprint(cmd.ErrOrStderr(), timeoutReport.String())
+		// --exit-code-from returns the exit code of its task, whatever happened to the jobs
+		if printJobSummary(cmd.OutOrStdout(), aborted) && exitCode == 0 && app.UpExitCodeFrom == "" {
+			exitCode = 1
+		}
 
 		if len(os.Args) == 1 && app.Portable == "true" && runtime.GOOS == "windows" {
 			utils.SharedAppLogger.Info("Program completed, Press ctrl-c to exit.")
@@ -63,10 +182,175 @@ var UpCmd = &cobra.Command{
 			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 			<-c
 		}
+
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
 	},
 }
 
+// shutdownTasks 依照反向依賴順序停止所有任務，並等待所有任務結束。
+// 停止期間再次收到 SIGINT/SIGTERM 時不再等待 stop_timeout，立即以 SIGKILL 結束所有任務
+func shutdownTasks(interrupt chan os.Signal, done <-chan struct{}) {
+	// the default handler would exit at once and leave the process groups of the tasks running
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	var stopped = make(chan struct{})
+	go func() {
+		stopTasks()
+		close(stopped)
+	}()
+	for stopped != nil || done != nil {
+		select {
+		case <-stopped:
+			stopped = nil
+		case <-done:
+			done = nil
+		case sig := <-interrupt:
+			utils.SharedAppLogger.Warn(fmt.Sprintf("Received %s again, killing tasks", sig))
+			for _, task := range AppTasks {
+				task.Kill()
+			}
+		}
+	}
+}
+
+// stopTasks 依照反向依賴順序停止所有任務
+func stopTasks() {
+	layers, err := config.AppConfig.GetLayeredStartupOrder()
+	if err != nil {
+		utils.SharedAppLogger.Warn(fmt.Sprintf("%v, stopping all tasks at once.", err))
+		var all []string
+		for name := range AppTasks {
+			all = append(all, name)
+		}
+		layers = [][]string{all}
+	}
+	stopInReverseOrder(layers, func(name string) {
+		AppTasks[name].Stop()
+	})
+}
+
+// abortOnExit 判斷任務結束時是否需要停止所有任務，成功完成的 job 不會觸發 --abort-on-exit
+func abortOnExit(task *procedure.Task) bool {
+	if app.UpExitCodeFrom != "" && task.Name == app.UpExitCodeFrom {
+		return true
+	}
+	if !app.UpAbortOnExit {
+		return false
+	}
+	if code, exited := task.ExitCode(); exited && code == 0 && task.Job {
+		return false
+	}
+	return true
+}
+
+// taskExitCode 回傳任務的結束代碼，沒有執行過的任務視為失敗
+func taskExitCode(task *procedure.Task) int {
+	if code, exited := task.ExitCode(); exited {
+		return code
+	}
+	return 1
+}
+
+// stackExitCode 在所有任務結束後，有任務無法啟動、沒有通過健康檢查，或 service 以非 0 結束且不再重新啟動時回傳 1。
+// job 的結果由 printJobSummary 依照 required 判斷
+func stackExitCode() int {
+	for _, task := range AppTasks {
+		if task.Failed() {
+			return 1
+		}
+		if code, exited := task.ExitCode(); !task.Job && exited && task.Completed() && code != 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// pendingTasks 依照設定檔的順序列出尚未啟動完成，且仍在等待或啟動中的任務
+func pendingTasks() []*procedure.Task {
+	var pending []*procedure.Task
+	for _, taskConfig := range config.AppConfig.Tasks {
+		var task = AppTasks[taskConfig.Name]
+		if task == nil || task.Ready() || task.State().Final() {
+			continue
+		}
+		pending = append(pending, task)
+	}
+	return pending
+}
+
+// printStartupReport 列出逾時時尚未啟動完成的任務，以及它們正在等待的條件
+func printStartupReport(out io.Writer, reason string, pending []*procedure.Task) {
+	fmt.Fprintf(out, "%s:\n", reason)
+	var writer = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "TASK\tSTATE\tWAITING ON")
+	for _, task := range pending {
+		var state = task.State()
+		var waitingOn = "-"
+		switch state {
+		case procedure.StateWaiting:
+			waitingOn = strings.Join(task.PendingDependencies(), ", ")
+		case procedure.StateStarting:
+			waitingOn = "process to start"
+			if task.Job {
+				waitingOn = "job to complete"
+			}
+		case procedure.StateProbing, procedure.StateUnhealthy:
+			waitingOn = fmt.Sprintf("healthcheck (%s)", task.Healthcheck.Kind())
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", task.Name, state, waitingOn)
+	}
+	_ = writer.Flush()
+}
+
+// printJobSummary 列出 job 的執行結果，回傳是否有 required 的 job 沒有成功完成。
+// aborted 時因為停止所有任務而被中斷或沒有執行的 job 不視為失敗
+func printJobSummary(out io.Writer, aborted bool) bool {
+	var failed bool
+	var writer = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	var header bool
+	for _, taskConfig := range config.AppConfig.Tasks {
+		var task = AppTasks[taskConfig.Name]
+		if task == nil || !task.Job {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(writer, "JOB\tSTATUS\tEXIT CODE\tREQUIRED")
+			header = true
+		}
+		var status, exit = "not run", "-"
+		if code, exited := task.ExitCode(); exited {
+			exit = strconv.Itoa(code)
+			status = "failed"
+			if !task.Completed() {
+				status = "stopped"
+			} else if code == 0 {
+				status = "succeeded"
+			}
+		}
+		if task.Required && (status == "failed" || (!aborted && status != "succeeded")) {
+			failed = true
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%t\n", task.Name, status, exit, task.Required)
+	}
+	_ = writer.Flush()
+	return failed
+}
+
+// signalExitCode 依照慣例以 128 + signal number 作為結束代碼
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
 func init() {
 	UpCmd.PersistentFlags().BoolVarP(&app.DetachMode, "detach", "d", false, "Launch tasks in the background")
+	UpCmd.PersistentFlags().BoolVar(&app.UpNoDeps, "no-deps", false, "Don't start the dependencies of the given tasks")
+	UpCmd.PersistentFlags().StringSliceVar(&app.UpExclude, "exclude", nil, "Skip the given tasks, can be repeated or comma separated")
+	UpCmd.PersistentFlags().BoolVar(&app.UpAbortOnExit, "abort-on-exit", false, "Stop all tasks when any task exits, successful jobs excluded")
+	UpCmd.PersistentFlags().StringVar(&app.UpExitCodeFrom, "exit-code-from", "", "Stop all tasks when the given task exits and return its exit code")
+	UpCmd.PersistentFlags().DurationVar(&app.UpTimeout, "timeout", 0, "Stop all tasks and fail when they have not started within the given duration, e.g. 2m")
 	UpCmd.PersistentFlags().StringVarP(&app.TasksComposeFile, "configfile", "f", "", "Specify the path to the configuration file, default is 'task-compose.yaml'")
 }
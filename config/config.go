@@ -2,16 +2,74 @@ package config
 
 import (
 	"fmt"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 	"github.com/vulcanshen-tpi/task-compose/app"
 	"github.com/vulcanshen-tpi/task-compose/utils"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 )
 
+// JSON 斷言支援的比較方式
+const (
+	JsonOperatorEquals    = "equals"
+	JsonOperatorNotEquals = "not_equals"
+	JsonOperatorGt        = "gt"
+	JsonOperatorLt        = "lt"
+	JsonOperatorRegex     = "regex"
+	JsonOperatorExists    = "exists"
+	JsonOperatorIn        = "in"
+)
+
+var JsonOperators = []string{
+	JsonOperatorEquals,
+	JsonOperatorNotEquals,
+	JsonOperatorGt,
+	JsonOperatorLt,
+	JsonOperatorRegex,
+	JsonOperatorExists,
+	JsonOperatorIn,
+}
+
+// HttpCheckExpectJson 定義了對 jsonpath 取得的值的斷言，value 保留 yaml 中的型別 (bool、number、null、string、list)
 type HttpCheckExpectJson struct {
-	Value    string `mapstructure:"value"`
+	Value    any    `mapstructure:"value"`
 	Jsonpath string `mapstructure:"jsonpath"`
+	Operator string `mapstructure:"operator"`
+	// ValueSet 區分沒有設定 value 與明確設定 value: null，由 jsonExpectHookFunc 設定
+	ValueSet bool `mapstructure:"-" json:"-"`
+}
+
+// GetOperator 回傳斷言的比較方式，未設定時有 value (包含 null) 為 equals，否則只檢查路徑存在
+func (e HttpCheckExpectJson) GetOperator() string {
+	if e.Operator != "" {
+		return strings.ToLower(e.Operator)
+	}
+	if !e.ValueSet {
+		return JsonOperatorExists
+	}
+	return JsonOperatorEquals
+}
+
+// jsonExpectHookFunc 解碼 expect.json 的斷言並記錄是否有設定 value，解碼後的 null 與沒有設定都是 nil
+func jsonExpectHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if to != reflect.TypeOf(HttpCheckExpectJson{}) {
+			return data, nil
+		}
+		fields, ok := data.(map[string]any)
+		if !ok {
+			return data, nil
+		}
+		var expect HttpCheckExpectJson
+		if err := mapstructure.WeakDecode(fields, &expect); err != nil {
+			return nil, err
+		}
+		_, expect.ValueSet = fields["value"]
+		return expect, nil
+	}
 }
 
 type HttpCheckExpectPlain struct {
@@ -19,20 +77,89 @@ type HttpCheckExpectPlain struct {
 }
 
 type HttpCheckExpect struct {
-	Json  *HttpCheckExpectJson  `mapstructure:"json"`
-	Plain *HttpCheckExpectPlain `mapstructure:"plain"`
+	Status []int                 `mapstructure:"status"`
+	Json   []HttpCheckExpectJson `mapstructure:"json"`
+	Plain  *HttpCheckExpectPlain `mapstructure:"plain"`
+}
+
+// HTTPAuth 定義了 HTTP 健康檢查的認證，bearer 與 basic (username/password) 擇一使用
+type HTTPAuth struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Bearer   string `mapstructure:"bearer"`
 }
 
 // HTTPCheck 定義了 HTTP 健康檢查的配置
 type HTTPCheck struct {
-	URL    string           `mapstructure:"url"`
-	Expect *HttpCheckExpect `mapstructure:"expect"`
+	URL                string            `mapstructure:"url"`
+	UnixSocket         string            `mapstructure:"unix_socket"`
+	Method             string            `mapstructure:"method"`
+	Headers            map[string]string `mapstructure:"headers"`
+	Body               string            `mapstructure:"body"`
+	Auth               *HTTPAuth         `mapstructure:"auth"`
+	InsecureSkipVerify bool              `mapstructure:"insecure_skip_verify"`
+	CAFile             string            `mapstructure:"ca_file"`
+	Expect             *HttpCheckExpect  `mapstructure:"expect"`
 }
 
 type CommandCheck struct {
 	Scripts []string `mapstructure:"scripts"`
 }
 
+// TCPCheck 定義了 TCP 連線的健康檢查，host 未設定時為 localhost
+type TCPCheck struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+}
+
+func (c *TCPCheck) Address() string {
+	if c.Host == "" {
+		return "localhost"
+	}
+	return c.Host
+}
+
+// RequestURL 回傳請求的網址，透過 unix socket 連線時 url 可以只寫路徑，例如 /health
+func (c *HTTPCheck) RequestURL() string {
+	if c.UnixSocket != "" && strings.HasPrefix(c.URL, "/") {
+		return "http://localhost" + c.URL
+	}
+	return c.URL
+}
+
+// GRPCCheck 定義了 grpc.health.v1 的健康檢查，service 未設定時檢查整個伺服器
+type GRPCCheck struct {
+	Address            string `mapstructure:"address"`
+	Service            string `mapstructure:"service"`
+	TLS                bool   `mapstructure:"tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	CAFile             string `mapstructure:"ca_file"`
+}
+
+// FileCheck 定義了以檔案存在判斷就緒的健康檢查，相對路徑以任務的 base_dir 為準
+type FileCheck struct {
+	Path           string `mapstructure:"path"`
+	MinSize        int64  `mapstructure:"min_size"`
+	NewerThanStart bool   `mapstructure:"newer_than_start"`
+}
+
+// SocketCheck 定義了只檢查 unix socket 能否連線的健康檢查
+type SocketCheck struct {
+	Path string `mapstructure:"path"`
+}
+
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+	LogStreamBoth   = "both"
+)
+
+// LogCheck 定義了以任務輸出判斷就緒的健康檢查
+type LogCheck struct {
+	Pattern string `mapstructure:"pattern"`
+	Stream  string `mapstructure:"stream"`
+}
+
 type CheckFrequency struct {
 	Interval string `mapstructure:"interval"`
 	Timeout  string `mapstructure:"timeout"`
@@ -43,24 +170,126 @@ type CheckFrequency struct {
 // HealthCheckConfig 定義了應用程式的健康檢查配置
 type HealthCheckConfig struct {
 	HTTP      *HTTPCheck      `mapstructure:"http"`
+	GRPC      *GRPCCheck      `mapstructure:"grpc"`
 	Command   *CommandCheck   `mapstructure:"command"`
+	TCP       *TCPCheck       `mapstructure:"tcp"`
+	Socket    *SocketCheck    `mapstructure:"socket"`
+	File      *FileCheck      `mapstructure:"file"`
+	Log       *LogCheck       `mapstructure:"log"`
 	Frequency *CheckFrequency `mapstructure:"frequency"`
 }
 
+const (
+	LivenessActionNone           = "none"
+	LivenessActionRestart        = "restart"
+	LivenessActionStopDependents = "stop_dependents"
+)
+
+// LivenessConfig 定義了任務就緒後持續執行的存活檢查，連續失敗 frequency.tries 次後視為不健康並執行 action
+type LivenessConfig struct {
+	HealthCheckConfig `mapstructure:",squash"`
+	Action            string `mapstructure:"action"`
+}
+
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// StopSignals 列出 stop_signal 可使用的訊號名稱
+var StopSignals = []string{"SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGKILL", "SIGUSR1", "SIGUSR2"}
+
+// NormalizeSignalName 將 term、sigterm 等寫法統一為 SIGTERM
+func NormalizeSignalName(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name != "" && !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	return name
+}
+
+// RestartConfig 定義了任務結束後的重新啟動策略
+type RestartConfig struct {
+	Policy     string `mapstructure:"policy"`
+	MaxRetries int    `mapstructure:"max_retries"`
+	Backoff    string `mapstructure:"backoff"`
+	MaxBackoff string `mapstructure:"max_backoff"`
+}
+
+// IsConfigured 判斷是否有設定任何一種健康檢查
+func (hc *HealthCheckConfig) IsConfigured() bool {
+	return hc.HTTP != nil || hc.GRPC != nil || hc.Command != nil || hc.TCP != nil || hc.Socket != nil || hc.File != nil || hc.Log != nil
+}
+
+// Kind 回傳已設定的健康檢查類型，例如 http、command，未設定時為 none
+func (hc *HealthCheckConfig) Kind() string {
+	var kinds []string
+	if hc.HTTP != nil {
+		kinds = append(kinds, "http")
+	}
+	if hc.GRPC != nil {
+		kinds = append(kinds, "grpc")
+	}
+	if hc.Command != nil {
+		kinds = append(kinds, "command")
+	}
+	if hc.TCP != nil {
+		kinds = append(kinds, "tcp")
+	}
+	if hc.Socket != nil {
+		kinds = append(kinds, "socket")
+	}
+	if hc.File != nil {
+		kinds = append(kinds, "file")
+	}
+	if hc.Log != nil {
+		kinds = append(kinds, "log")
+	}
+	if len(kinds) == 0 {
+		return "none"
+	}
+	return strings.Join(kinds, "+")
+}
+
+// 任務的類型，未設定時為 service
+const (
+	TaskTypeService = "service"
+	TaskTypeJob     = "job"
+)
+
 // TaskConfig 定義了單個應用程式的配置
 type TaskConfig struct {
-	Name        string            `mapstructure:"name"`
-	BaseDir     string            `mapstructure:"base_dir"`
-	Envs        []string          `mapstructure:"envs"`
-	Executable  string            `mapstructure:"executable"`
-	Args        []string          `mapstructure:"args"`
-	Healthcheck HealthCheckConfig `mapstructure:"healthcheck"`
-	DependsOn   []string          `mapstructure:"depends_on"`
+	Name         string            `mapstructure:"name"`
+	Type         string            `mapstructure:"type"`
+	Required     *bool             `mapstructure:"required"`
+	BaseDir      string            `mapstructure:"base_dir"`
+	EnvFile      []string          `mapstructure:"env_file"`
+	Envs         []string          `mapstructure:"envs"`
+	EnvInherit   *bool             `mapstructure:"env_inherit"`
+	EnvAllowlist []string          `mapstructure:"env_allowlist"`
+	Executable   string            `mapstructure:"executable"`
+	Args         []string          `mapstructure:"args"`
+	Healthcheck  HealthCheckConfig `mapstructure:"healthcheck"`
+	Liveness     *LivenessConfig   `mapstructure:"liveness"`
+	Restart      *RestartConfig    `mapstructure:"restart"`
+	StopSignal   string            `mapstructure:"stop_signal"`
+	StopTimeout  string            `mapstructure:"stop_timeout"`
+	DependsOn    Dependencies      `mapstructure:"depends_on"`
+	StartTimeout string            `mapstructure:"start_timeout"`
+}
+
+// IsJob 判斷任務是否為執行一次就結束的 job，未設定 type 時為 service
+func (tc *TaskConfig) IsJob() bool {
+	return strings.ToLower(tc.Type) == TaskTypeJob
 }
 
 // LauncherConfig 定義了整個 task-compose.yaml 的根配置
 type LauncherConfig struct {
-	Tasks []TaskConfig `mapstructure:"tasks"`
+	Variables map[string]string `mapstructure:"variables"`
+	EnvFile   []string          `mapstructure:"env_file"`
+	Tasks     []TaskConfig      `mapstructure:"tasks"`
 }
 
 const defaultFileName = "task-compose"
@@ -73,7 +302,7 @@ func GetDefaultFileNameWithExtension() string {
 	return fmt.Sprintf("%s.yaml", defaultFileName)
 }
 
-func InitConfig() {
+func InitConfig() error {
 	//logger := log.New(os.Stdout, "", 0)
 	viper.SetEnvPrefix("CMD_COMPOSE")
 	viper.AutomaticEnv()
@@ -91,15 +320,26 @@ func InitConfig() {
 
 	viper.SetConfigFile(app.TasksComposeFile)
 
-	if err := viper.ReadInConfig(); err == nil {
-		utils.SharedAppLogger.Info(fmt.Sprintf("Using config file: %s", viper.ConfigFileUsed()))
-		//logger.Printf("%s|%s", AppLogPrefix, fmt.Sprintf("Using config file: %s", viper.ConfigFileUsed()))
-	} else {
-		utils.SharedAppLogger.Fatal(err)
-		//logger.Fatalf("%s|%s", AppLogPrefix, utils.Convertor.ToErrorColor(err.Error()))
+	if err := viper.ReadInConfig(); err != nil {
+		return err
 	}
+	utils.SharedAppLogger.Info(fmt.Sprintf("Using config file: %s", viper.ConfigFileUsed()))
 
-	if err := viper.Unmarshal(&AppConfig); err != nil {
-		utils.SharedAppLogger.Fatal(err)
+	var hooks = mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		dependenciesHookFunc(),
+		jsonExpectHookFunc(),
+	)
+	if err := viper.Unmarshal(&AppConfig, viper.DecodeHook(hooks)); err != nil {
+		return err
 	}
+
+	AppConfig.resolveDependencyNames()
+
+	if err := AppConfig.interpolate(); err != nil {
+		return err
+	}
+
+	return AppConfig.loadEnvFiles(filepath.Dir(app.TasksComposeFile))
 }
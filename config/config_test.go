@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/go-viper/mapstructure/v2"
+	"testing"
+)
+
+func TestJsonExpectHookFunc(t *testing.T) {
+	var input = map[string]any{
+		"json": []any{
+			map[string]any{"jsonpath": "$.status"},
+			map[string]any{"jsonpath": "$.error", "value": nil},
+			map[string]any{"jsonpath": "$.ready", "value": true},
+			map[string]any{"jsonpath": "$.latency", "value": "100", "operator": "lt"},
+		},
+	}
+	var expect HttpCheckExpect
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       jsonExpectHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &expect,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoder.Decode(input); err != nil {
+		t.Fatal(err)
+	}
+
+	var want = []struct {
+		operator string
+		valueSet bool
+	}{
+		{operator: JsonOperatorExists, valueSet: false},
+		{operator: JsonOperatorEquals, valueSet: true},
+		{operator: JsonOperatorEquals, valueSet: true},
+		{operator: JsonOperatorLt, valueSet: true},
+	}
+	if len(expect.Json) != len(want) {
+		t.Fatalf("decoded %d assertions, want %d", len(expect.Json), len(want))
+	}
+	for i, w := range want {
+		var got = expect.Json[i]
+		if got.GetOperator() != w.operator || got.ValueSet != w.valueSet {
+			t.Errorf("json[%d] %s: operator %s, value set %t, want %s, %t",
+				i, got.Jsonpath, got.GetOperator(), got.ValueSet, w.operator, w.valueSet)
+		}
+	}
+	if expect.Json[2].Value != true {
+		t.Errorf("json[2] value = %#v, want true", expect.Json[2].Value)
+	}
+}
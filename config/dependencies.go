@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"github.com/go-viper/mapstructure/v2"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// depends_on 支援的條件
+const (
+	DependencyStarted               = "started"
+	DependencyHealthy               = "healthy"
+	DependencyCompletedSuccessfully = "completed_successfully"
+)
+
+// Dependency 定義了對另一個任務的依賴，以及啟動前需要滿足的條件
+type Dependency struct {
+	Name      string `mapstructure:"name"`
+	Condition string `mapstructure:"condition"`
+}
+
+// Dependencies 可以寫成任務名稱的清單，或以任務名稱為 key 的長格式:
+//
+//	depends_on:
+//	  migrate:
+//	    condition: completed_successfully
+//	  cache:
+//	    condition: started
+type Dependencies []Dependency
+
+// Names 回傳依賴的任務名稱
+func (d Dependencies) Names() []string {
+	var names []string
+	for _, dependency := range d {
+		names = append(names, dependency.Name)
+	}
+	return names
+}
+
+// dependenciesHookFunc 將 depends_on 的清單或長格式轉換為 Dependencies
+func dependenciesHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if to != reflect.TypeOf(Dependencies{}) {
+			return data, nil
+		}
+		switch value := data.(type) {
+		case string:
+			return Dependencies{{Name: value}}, nil
+		case []any:
+			var dependencies Dependencies
+			for _, item := range value {
+				name, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("depends_on entries must be task names, got %v", item)
+				}
+				dependencies = append(dependencies, Dependency{Name: name})
+			}
+			return dependencies, nil
+		case map[string]any:
+			// map keys have no order, keep the output stable
+			var names []string
+			for name := range value {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var dependencies Dependencies
+			for _, name := range names {
+				var dependency = Dependency{Name: name}
+				if options := value[name]; options != nil {
+					if err := mapstructure.Decode(options, &dependency); err != nil {
+						return nil, fmt.Errorf("depends_on %s: %v", name, err)
+					}
+					dependency.Name = name
+				}
+				dependencies = append(dependencies, dependency)
+			}
+			return dependencies, nil
+		}
+		return data, nil
+	}
+}
+
+// resolveDependencyNames 還原長格式 depends_on 中任務名稱的大小寫，viper 讀取設定時會將 map 的 key 轉為小寫
+func (lc *LauncherConfig) resolveDependencyNames() {
+	var names = make(map[string]bool)
+	for _, task := range lc.Tasks {
+		names[task.Name] = true
+	}
+	for _, task := range lc.Tasks {
+		for i, dependency := range task.DependsOn {
+			if names[dependency.Name] {
+				continue
+			}
+			for _, candidate := range lc.Tasks {
+				if strings.EqualFold(candidate.Name, dependency.Name) {
+					task.DependsOn[i].Name = candidate.Name
+					break
+				}
+			}
+		}
+	}
+}
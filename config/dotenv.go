@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadEnvFiles 讀取全域與任務的 env_file，並依照以下順序合併到任務的 envs (後者覆蓋前者)：
+// 全域 env_file、任務 env_file、任務 envs
+func (lc *LauncherConfig) loadEnvFiles(baseDir string) error {
+	globalEnvs, err := readEnvFiles(lc.EnvFile, baseDir)
+	if err != nil {
+		return err
+	}
+	for i := range lc.Tasks {
+		var task = &lc.Tasks[i]
+		taskEnvs, err := readEnvFiles(task.EnvFile, baseDir)
+		if err != nil {
+			return fmt.Errorf("task %s %v", task.Name, err)
+		}
+		if len(globalEnvs) > 0 || len(taskEnvs) > 0 {
+			task.Envs = MergeEnvs(globalEnvs, taskEnvs, task.Envs)
+		}
+	}
+	return nil
+}
+
+// readEnvFiles 依序讀取 dotenv 檔案，相對路徑以設定檔所在目錄為基準
+func readEnvFiles(files []string, baseDir string) ([]string, error) {
+	var envs []string
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(baseDir, file)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("env_file: %v", err)
+		}
+		parsed, err := ParseDotenv(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("env_file %s: %v", file, err)
+		}
+		envs = MergeEnvs(envs, parsed)
+	}
+	return envs, nil
+}
+
+// MergeEnvs 合併多組 KEY=VALUE，同名變數以後面的值為準，並保留第一次出現的順序
+func MergeEnvs(lists ...[]string) []string {
+	var merged []string
+	var index = make(map[string]int)
+	for _, list := range lists {
+		for _, env := range list {
+			var key = EnvKey(env)
+			if i, ok := index[key]; ok {
+				merged[i] = env
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, env)
+		}
+	}
+	return merged
+}
+
+// EnvKey 回傳 KEY=VALUE 中的 KEY
+func EnvKey(env string) string {
+	// windows keeps per-drive directories in variables such as "=C:=C:\", the leading '=' belongs to the key
+	var start = 0
+	if strings.HasPrefix(env, "=") {
+		start = 1
+	}
+	if i := strings.IndexByte(env[start:], '='); i >= 0 {
+		return env[:start+i]
+	}
+	return env
+}
+
+// ParseDotenv 解析 dotenv 格式，支援註解、export 前綴、單引號 (原文)、雙引號 (跳脫字元與多行) 與未加引號的值
+func ParseDotenv(content string) ([]string, error) {
+	var envs []string
+	var line = 1
+	var i = 0
+	for i < len(content) {
+		// skip blank lines and leading spaces
+		if content[i] == '\n' {
+			line++
+			i++
+			continue
+		}
+		if content[i] == ' ' || content[i] == '\t' || content[i] == '\r' {
+			i++
+			continue
+		}
+		if content[i] == '#' {
+			i = skipLine(content, i)
+			continue
+		}
+
+		var rest = content[i:]
+		if strings.HasPrefix(rest, "export ") || strings.HasPrefix(rest, "export\t") {
+			i += len("export")
+			for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+				i++
+			}
+		}
+
+		var keyStart = i
+		for i < len(content) && isEnvKeyChar(content[i]) {
+			i++
+		}
+		var key = content[keyStart:i]
+		if key == "" || (key[0] >= '0' && key[0] <= '9') {
+			return nil, fmt.Errorf("line %d: invalid variable name", line)
+		}
+		for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+			i++
+		}
+		if i >= len(content) || content[i] != '=' {
+			return nil, fmt.Errorf("line %d: expected '=' after %s", line, key)
+		}
+		i++
+		for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+			i++
+		}
+
+		var value string
+		var startLine = line
+		if i < len(content) && (content[i] == '\'' || content[i] == '"') {
+			var quote = content[i]
+			var builder strings.Builder
+			var closed = false
+			for i++; i < len(content); i++ {
+				var c = content[i]
+				if c == '\n' {
+					line++
+				}
+				if c == quote {
+					closed = true
+					i++
+					break
+				}
+				if quote == '"' && c == '\\' && i+1 < len(content) {
+					i++
+					switch content[i] {
+					case 'n':
+						builder.WriteByte('\n')
+					case 'r':
+						builder.WriteByte('\r')
+					case 't':
+						builder.WriteByte('\t')
+					case '\\', '"', '$':
+						builder.WriteByte(content[i])
+					default:
+						builder.WriteByte('\\')
+						builder.WriteByte(content[i])
+					}
+					continue
+				}
+				builder.WriteByte(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("line %d: unterminated quoted value for %s", startLine, key)
+			}
+			value = builder.String()
+			// only a comment may follow a quoted value
+			var end = skipLine(content, i)
+			if trailing := strings.TrimSpace(content[i:end]); trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return nil, fmt.Errorf("line %d: unexpected characters after the value of %s", line, key)
+			}
+			i = end
+		} else {
+			var end = skipLine(content, i)
+			value = content[i:end]
+			// an unquoted value ends at a comment preceded by whitespace, "A=#fff" keeps its value
+			for j := 0; j < len(value); j++ {
+				var previous = content[i+j-1]
+				if value[j] == '#' && (previous == ' ' || previous == '\t') {
+					value = value[:j]
+					break
+				}
+			}
+			value = strings.TrimSpace(value)
+			i = end
+		}
+		envs = append(envs, key+"="+value)
+	}
+	return envs, nil
+}
+
+func isEnvKeyChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// skipLine 回傳下一個換行字元的位置 (不包含換行字元)
+func skipLine(content string, i int) int {
+	if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(content)
+}
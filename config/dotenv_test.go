@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "empty", content: "", want: nil},
+		{name: "plain", content: "A=1\nB=two", want: []string{"A=1", "B=two"}},
+		{name: "crlf", content: "A=1\r\nB=2\r\n", want: []string{"A=1", "B=2"}},
+		{name: "blank lines and comments", content: "\n# comment\n  \nA=1\n\t# indented comment\n", want: []string{"A=1"}},
+		{name: "spaces around equals", content: "A = 1 ", want: []string{"A=1"}},
+		{name: "empty value", content: "A=\nB=", want: []string{"A=", "B="}},
+		{name: "export prefix", content: "export A=1\nexport\tB=2", want: []string{"A=1", "B=2"}},
+		{name: "export as a name", content: "export=1\nexporter=2", want: []string{"export=1", "exporter=2"}},
+		{name: "key characters", content: "app.name-v2_X=1", want: []string{"app.name-v2_X=1"}},
+		{name: "inline comment", content: "A=1 # one\nB=2\t# two", want: []string{"A=1", "B=2"}},
+		{name: "comment instead of value", content: "A= # none\nB=2", want: []string{"A=", "B=2"}},
+		{name: "hash without space is kept", content: "COLOR=#fff\nURL=a#b", want: []string{"COLOR=#fff", "URL=a#b"}},
+		{name: "equals in value", content: "DSN=user=a password=b", want: []string{"DSN=user=a password=b"}},
+		{name: "single quoted is raw", content: `A='a\nb ${X} # c'`, want: []string{`A=a\nb ${X} # c`}},
+		{name: "double quoted escapes", content: `A="a\nb\tc\\d\"e\$f\qg"`, want: []string{"A=a\nb\tc\\d\"e$f\\qg"}},
+		{name: "double quoted keeps hash", content: `A="a # b" # comment`, want: []string{"A=a # b"}},
+		{name: "quoted keeps spaces", content: `A="  padded  "`, want: []string{"A=  padded  "}},
+		{name: "multiline double quoted", content: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1", want: []string{"KEY=-----BEGIN-----\nabc\n-----END-----", "NEXT=1"}},
+		{name: "multiline single quoted", content: "A='line1\nline2'", want: []string{"A=line1\nline2"}},
+		{name: "duplicates are kept in order", content: "A=1\nA=2", want: []string{"A=1", "A=2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseDotenv(test.content)
+			if err != nil {
+				t.Fatalf("ParseDotenv returned error: %v", err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("ParseDotenv(%q) = %q, want %q", test.content, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "missing equals", content: "A=1\nB", want: "line 2: expected '=' after B"},
+		{name: "invalid name", content: "=1", want: "line 1: invalid variable name"},
+		{name: "name starts with a digit", content: "1A=1", want: "line 1: invalid variable name"},
+		{name: "unterminated quote", content: "A=1\n\nB=\"open\nstill open", want: "line 3: unterminated quoted value for B"},
+		{name: "text after quoted value", content: "A=\"1\" 2", want: "line 1: unexpected characters after the value of A"},
+		{name: "line after multiline value", content: "A=\"1\n2\" x", want: "line 2: unexpected characters after the value of A"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseDotenv(test.content)
+			if err == nil {
+				t.Fatalf("ParseDotenv(%q) returned no error, want %q", test.content, test.want)
+			}
+			if err.Error() != test.want {
+				t.Errorf("ParseDotenv(%q) error = %q, want %q", test.content, err.Error(), test.want)
+			}
+		})
+	}
+}
+
+func TestMergeEnvs(t *testing.T) {
+	var got = MergeEnvs([]string{"A=1", "B=1"}, []string{"C=2", "A=2"}, []string{"B=3"})
+	var want = []string{"A=2", "B=3", "C=2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("MergeEnvs = %q, want %q", got, want)
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	var tests = map[string]string{
+		"A=1":       "A",
+		"A=b=c":     "A",
+		"A=":        "A",
+		"A":         "A",
+		`=C:=C:\`:   "=C:",
+		"=ExitCode": "=ExitCode",
+	}
+	for env, want := range tests {
+		if got := EnvKey(env); got != want {
+			t.Errorf("EnvKey(%q) = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestLoadEnvFiles(t *testing.T) {
+	var dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "global.env"), []byte("A=global\nB=global\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "task.env"), []byte("B=task\nC=task\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var config = LauncherConfig{
+		EnvFile: []string{"global.env"},
+		Tasks: []TaskConfig{
+			{Name: "api", EnvFile: []string{filepath.Join(dir, "task.env")}, Envs: []string{"C=envs"}},
+			{Name: "worker"},
+		},
+	}
+	if err := config.loadEnvFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A=global", "B=task", "C=envs"}; !slices.Equal(config.Tasks[0].Envs, want) {
+		t.Errorf("api envs = %q, want %q", config.Tasks[0].Envs, want)
+	}
+	if want := []string{"A=global", "B=global"}; !slices.Equal(config.Tasks[1].Envs, want) {
+		t.Errorf("worker envs = %q, want %q", config.Tasks[1].Envs, want)
+	}
+
+	config.Tasks[1].EnvFile = []string{"missing.env"}
+	var err = config.loadEnvFiles(dir)
+	if err == nil || !strings.HasPrefix(err.Error(), "task worker env_file:") {
+		t.Errorf("loadEnvFiles error = %v, want a task worker env_file error", err)
+	}
+}
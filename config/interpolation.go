@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// lookupVariable 先查詢環境變數，再查詢設定檔的 variables (viper 會將 key 轉為小寫)
+func (lc *LauncherConfig) lookupVariable(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := lc.Variables[strings.ToLower(name)]
+	return value, ok
+}
+
+// interpolate 將所有任務設定中字串欄位的 ${VAR} 取代為變數值
+func (lc *LauncherConfig) interpolate() error {
+	for name, value := range lc.Variables {
+		resolved, err := interpolate(value, os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("variables.%s: %v", name, err)
+		}
+		lc.Variables[name] = resolved
+	}
+
+	if err := interpolateValue(reflect.ValueOf(&lc.EnvFile).Elem(), lc.lookupVariable); err != nil {
+		return fmt.Errorf("env_file%v", err)
+	}
+
+	for i := range lc.Tasks {
+		if err := interpolateValue(reflect.ValueOf(&lc.Tasks[i]).Elem(), lc.lookupVariable); err != nil {
+			return fmt.Errorf("task %s %v", lc.Tasks[i].Name, strings.TrimPrefix(err.Error(), "."))
+		}
+	}
+	return nil
+}
+
+// interpolateValue 遞迴處理 struct、pointer、slice 與 map 中的字串
+func interpolateValue(value reflect.Value, lookup func(name string) (string, bool)) error {
+	switch value.Kind() {
+	case reflect.String:
+		resolved, err := interpolate(value.String(), lookup)
+		if err != nil {
+			return fmt.Errorf(": %v", err)
+		}
+		value.SetString(resolved)
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		if value.Kind() == reflect.Interface {
+			// values held by an interface are not addressable, only plain strings are replaced
+			if text, ok := value.Interface().(string); ok {
+				resolved, err := interpolate(text, lookup)
+				if err != nil {
+					return fmt.Errorf(": %v", err)
+				}
+				value.Set(reflect.ValueOf(resolved))
+			}
+			return nil
+		}
+		return interpolateValue(value.Elem(), lookup)
+	case reflect.Struct:
+		// field paths are reported with their yaml keys, e.g. healthcheck.http.url
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := interpolateValue(value.Field(i), lookup); err != nil {
+				var key, _, _ = strings.Cut(value.Type().Field(i).Tag.Get("mapstructure"), ",")
+				if value.Type().Field(i).Anonymous && key == "" {
+					// squashed structs share the keys of their parent
+					return err
+				}
+				if key == "" {
+					key = value.Type().Field(i).Name
+				}
+				return fmt.Errorf(".%s%v", key, err)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := interpolateValue(value.Index(i), lookup); err != nil {
+				return fmt.Errorf("[%d]%v", i, err)
+			}
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			var element = reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			if err := interpolateValue(element, lookup); err != nil {
+				return fmt.Errorf(".%v%v", key, err)
+			}
+			value.SetMapIndex(key, element)
+		}
+	default:
+	}
+	return nil
+}
+
+// interpolate 取代字串中的 ${VAR}、${VAR:-default}、${VAR-default}、${VAR:?error}、${VAR?error}，$$ 代表 $ 字元
+func interpolate(text string, lookup func(name string) (string, bool)) (string, error) {
+	if !strings.Contains(text, "$") {
+		return text, nil
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 >= len(text) {
+			result.WriteByte(text[i])
+			continue
+		}
+		switch text[i+1] {
+		case '$':
+			result.WriteByte('$')
+			i++
+		case '{':
+			var end = matchingBrace(text, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", text)
+			}
+			resolved, err := resolveVariable(text[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(resolved)
+			i = end
+		default:
+			result.WriteByte('$')
+		}
+	}
+	return result.String(), nil
+}
+
+// matchingBrace 回傳與 start 位置的 { 對應的 } 位置，允許預設值中再包含 ${...}
+func matchingBrace(text string, start int) int {
+	var depth = 0
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func resolveVariable(expression string, lookup func(name string) (string, bool)) (string, error) {
+	var name = expression
+	var operator, argument string
+	if index := strings.IndexAny(expression, ":-?"); index >= 0 {
+		name = expression[:index]
+		operator = expression[index:]
+		for _, candidate := range []string{":-", ":?", "-", "?"} {
+			if strings.HasPrefix(operator, candidate) {
+				argument = operator[len(candidate):]
+				operator = candidate
+				break
+			}
+		}
+		if operator != ":-" && operator != ":?" && operator != "-" && operator != "?" {
+			return "", fmt.Errorf("invalid variable reference ${%s}", expression)
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expression)
+	}
+
+	value, ok := lookup(name)
+	if !ok && operator == "" {
+		utils.SharedAppLogger.Warn(fmt.Sprintf("variable %s is not set, defaulting to a blank string", name))
+	}
+	// the forms with a colon also treat an empty value as unset
+	var unset = !ok || (value == "" && strings.HasPrefix(operator, ":"))
+
+	switch operator {
+	case ":-", "-":
+		if unset {
+			return interpolate(argument, lookup)
+		}
+	case ":?", "?":
+		if unset {
+			message, err := interpolate(argument, lookup)
+			if err != nil {
+				return "", err
+			}
+			if message == "" {
+				message = "required variable is not set"
+			}
+			return "", fmt.Errorf("variable %s: %s", name, message)
+		}
+	}
+	return value, nil
+}
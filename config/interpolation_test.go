@@ -0,0 +1,145 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func mapLookup(values map[string]string) func(name string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	var lookup = mapLookup(map[string]string{
+		"HOST":  "localhost",
+		"PORT":  "8080",
+		"EMPTY": "",
+		"NEST":  "${HOST}",
+	})
+	var tests = []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "no reference", text: "plain text", want: "plain text"},
+		{name: "braced", text: "${HOST}:${PORT}", want: "localhost:8080"},
+		{name: "unset is blank", text: "[${MISSING}]", want: "[]"},
+		{name: "unbraced is kept", text: "$HOST", want: "$HOST"},
+		{name: "trailing dollar", text: "cost$", want: "cost$"},
+		{name: "escaped dollar", text: "$${HOST}", want: "${HOST}"},
+		{name: "escaped pid", text: "echo $$$$", want: "echo $$"},
+		{name: "colon default when unset", text: "${MISSING:-fallback}", want: "fallback"},
+		{name: "colon default when empty", text: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "colon default when set", text: "${PORT:-9090}", want: "8080"},
+		{name: "dash default when unset", text: "${MISSING-fallback}", want: "fallback"},
+		{name: "dash default keeps empty", text: "[${EMPTY-fallback}]", want: "[]"},
+		{name: "default with colon inside", text: "${MISSING:-http://a:1}", want: "http://a:1"},
+		{name: "nested default", text: "${MISSING:-${HOST}:${PORT}}", want: "localhost:8080"},
+		{name: "nested default unused", text: "${PORT:-${MISSING:?never}}", want: "8080"},
+		{name: "value is not interpolated again", text: "${NEST}", want: "${HOST}"},
+		{name: "question mark when set", text: "${HOST:?required}", want: "localhost"},
+		{name: "question mark keeps empty", text: "[${EMPTY?required}]", want: "[]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := interpolate(test.text, lookup)
+			if err != nil {
+				t.Fatalf("interpolate(%q) returned error: %v", test.text, err)
+			}
+			if got != test.want {
+				t.Errorf("interpolate(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	var lookup = mapLookup(map[string]string{"EMPTY": "", "NAME": "db"})
+	var tests = []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "colon required when unset", text: "${MISSING:?set MISSING}", want: "variable MISSING: set MISSING"},
+		{name: "colon required when empty", text: "${EMPTY:?must not be empty}", want: "variable EMPTY: must not be empty"},
+		{name: "required when unset", text: "${MISSING?}", want: "variable MISSING: required variable is not set"},
+		{name: "required message is interpolated", text: "${MISSING:?no ${NAME}}", want: "variable MISSING: no db"},
+		{name: "unterminated", text: "${HOST", want: "unterminated variable reference"},
+		{name: "empty name", text: "${}", want: "invalid variable reference ${}"},
+		{name: "empty name with default", text: "${:-x}", want: "invalid variable reference ${:-x}"},
+		{name: "unknown operator", text: "${HOST:x}", want: "invalid variable reference ${HOST:x}"},
+		{name: "error in nested default", text: "${MISSING:-${OTHER:?nested}}", want: "variable OTHER: nested"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := interpolate(test.text, lookup)
+			if err == nil {
+				t.Fatalf("interpolate(%q) returned no error, want %q", test.text, test.want)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("interpolate(%q) error = %q, want %q", test.text, err.Error(), test.want)
+			}
+		})
+	}
+}
+
+func TestLauncherConfigInterpolate(t *testing.T) {
+	t.Setenv("TC_TEST_PORT", "9090")
+	var config = LauncherConfig{
+		// viper lowercases the keys of variables
+		Variables: map[string]string{"host": "localhost", "url": "http://${TC_TEST_PORT_HOST:-127.0.0.1}"},
+		Tasks: []TaskConfig{{
+			Name:        "api",
+			Executable:  "${HOST}-server",
+			Args:        []string{"--port", "${TC_TEST_PORT}", "--url", "${URL}"},
+			Envs:        []string{"ADDR=${HOST}:${TC_TEST_PORT}"},
+			Healthcheck: HealthCheckConfig{HTTP: &HTTPCheck{URL: "http://${HOST}:${TC_TEST_PORT}/health"}},
+		}},
+	}
+	if err := config.interpolate(); err != nil {
+		t.Fatal(err)
+	}
+	var task = config.Tasks[0]
+	if task.Executable != "localhost-server" {
+		t.Errorf("executable = %q", task.Executable)
+	}
+	if got := strings.Join(task.Args, " "); got != "--port 9090 --url http://127.0.0.1" {
+		t.Errorf("args = %q", got)
+	}
+	if task.Envs[0] != "ADDR=localhost:9090" {
+		t.Errorf("envs = %q", task.Envs[0])
+	}
+	if task.Healthcheck.HTTP.URL != "http://localhost:9090/health" {
+		t.Errorf("healthcheck.http.url = %q", task.Healthcheck.HTTP.URL)
+	}
+}
+
+func TestLauncherConfigInterpolateErrorPath(t *testing.T) {
+	var config = LauncherConfig{
+		Tasks: []TaskConfig{{
+			Name:        "api",
+			Healthcheck: HealthCheckConfig{HTTP: &HTTPCheck{Headers: map[string]string{"authorization": "${TC_TEST_TOKEN:?token}"}}},
+			Liveness:    &LivenessConfig{HealthCheckConfig: HealthCheckConfig{TCP: &TCPCheck{Host: "${TC_TEST_HOST:?host}"}}},
+		}},
+	}
+	var err = config.interpolate()
+	if err == nil {
+		t.Fatal("interpolate returned no error")
+	}
+	if want := "task api healthcheck.http.headers.authorization: variable TC_TEST_TOKEN: token"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+
+	config.Tasks[0].Healthcheck = HealthCheckConfig{}
+	err = config.interpolate()
+	if err == nil {
+		t.Fatal("interpolate returned no error")
+	}
+	// liveness squashes the healthcheck fields into its own keys
+	if want := "task api liveness.tcp.host: variable TC_TEST_HOST: host"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
@@ -2,6 +2,13 @@ package config
 
 import (
 	"fmt"
+	"github.com/oliveagle/jsonpath"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type TaskConfigCheck int
@@ -23,15 +30,43 @@ func (lc *LauncherConfig) Validate() error {
 		}
 		tasks[config.Name] = config
 		taskChecks[config.Name] = Unvisited
+
+		if err := validateType(config); err != nil {
+			return err
+		}
+
+		if err := validateRestart(config); err != nil {
+			return err
+		}
+
+		if err := validateStop(config); err != nil {
+			return err
+		}
+
+		if err := validateDuration(config.Name, "start_timeout", config.StartTimeout); err != nil {
+			return err
+		}
+
+		if err := validateHealthcheck(config.Name, "healthcheck", &config.Healthcheck); err != nil {
+			return err
+		}
+
+		if err := validateLiveness(config); err != nil {
+			return err
+		}
+
+		for _, pattern := range config.EnvAllowlist {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("task %s env_allowlist has an invalid pattern %q: %v", config.Name, pattern, err)
+			}
+		}
 	}
 
 	// check for missing dependencies
 	for name, task := range tasks {
-		if len(task.DependsOn) > 0 {
-			for _, dependency := range task.DependsOn {
-				if _, ok := tasks[dependency]; !ok {
-					return fmt.Errorf("task %s missing dependency %s", name, dependency)
-				}
+		for _, dependency := range task.DependsOn {
+			if _, ok := tasks[dependency.Name]; !ok {
+				return fmt.Errorf("task %s missing dependency %s", name, dependency.Name)
 			}
 		}
 	}
@@ -47,22 +82,33 @@ func (lc *LauncherConfig) Validate() error {
 	}
 
 	for _, task := range configs {
-		for _, dependencyName := range task.DependsOn {
-			depConfig, ok := tasks[dependencyName]
+		for i, dependency := range task.DependsOn {
+			depConfig, ok := tasks[dependency.Name]
 			if !ok {
 				// 這個錯誤應該在 ValidateConfig 中被捕獲，這裡是二次防禦
-				return fmt.Errorf("internal error: dependency %s for task %s not found", dependencyName, task.Name)
+				return fmt.Errorf("internal error: dependency %s for task %s not found", dependency.Name, task.Name)
 			}
 
-			// 檢查依賴任務是否有設定 healthcheck
-			// healthcheck.HTTP.URL 和 healthcheck.Command.Scripts 都是 string，如果沒有設定，Go 的零值是 "" 或 nil slice
-			// 判斷方式：如果 HTTP.URL 非空，或者 Command.Scripts 非空且長度大於 0
-			hasHTTPHealthcheck := depConfig.Healthcheck.HTTP != nil && depConfig.Healthcheck.HTTP.URL != ""
-			hasCommandHealthcheck := depConfig.Healthcheck.Command != nil && depConfig.Healthcheck.Command.Scripts != nil && len(depConfig.Healthcheck.Command.Scripts) > 0
-
-			if !hasHTTPHealthcheck && !hasCommandHealthcheck {
-				return fmt.Errorf("task %s depends on %s, but %s has no healthcheck configured. All depended-on tasks must have a healthcheck",
-					task.Name, dependencyName, dependencyName)
+			// 未指定條件時，有 healthcheck 的任務需要健康，否則只需要已啟動
+			switch dependency.Condition {
+			case "":
+				if depConfig.IsJob() {
+					task.DependsOn[i].Condition = DependencyCompletedSuccessfully
+				} else if depConfig.Healthcheck.IsConfigured() {
+					task.DependsOn[i].Condition = DependencyHealthy
+				} else {
+					task.DependsOn[i].Condition = DependencyStarted
+				}
+			case DependencyHealthy:
+				// 各類型的必要欄位已在 validateHealthcheck 中檢查
+				if !depConfig.Healthcheck.IsConfigured() {
+					return fmt.Errorf("task %s depends on %s being healthy, but %s has no healthcheck configured",
+						task.Name, dependency.Name, dependency.Name)
+				}
+			case DependencyStarted, DependencyCompletedSuccessfully:
+			default:
+				return fmt.Errorf("task %s has unknown depends_on condition %q for %s, expected one of: %s, %s, %s",
+					task.Name, dependency.Condition, dependency.Name, DependencyStarted, DependencyHealthy, DependencyCompletedSuccessfully)
 			}
 		}
 	}
@@ -71,11 +117,264 @@ func (lc *LauncherConfig) Validate() error {
 	return nil
 }
 
+// Select 回傳只包含指定任務的設定，withDependencies 為 true 時一併包含其遞移依賴的任務。
+// 未選取的任務會從 depends_on 中移除，names 為空時代表所有任務。
+func (lc *LauncherConfig) Select(names []string, withDependencies bool, exclude []string) (LauncherConfig, error) {
+	var tasks = make(map[string]TaskConfig)
+	for _, task := range lc.Tasks {
+		tasks[task.Name] = task
+	}
+	for _, name := range append(append([]string{}, names...), exclude...) {
+		if _, ok := tasks[name]; !ok {
+			return LauncherConfig{}, fmt.Errorf("unknown task: %s", name)
+		}
+	}
+
+	var selected = make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if selected[name] {
+			return
+		}
+		selected[name] = true
+		if withDependencies {
+			for _, dependency := range tasks[name].DependsOn {
+				if _, ok := tasks[dependency.Name]; ok {
+					visit(dependency.Name)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		for name := range tasks {
+			selected[name] = true
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	for _, name := range exclude {
+		delete(selected, name)
+	}
+
+	var result = *lc
+	result.Tasks = nil
+	for _, task := range lc.Tasks {
+		if !selected[task.Name] {
+			continue
+		}
+		var dependsOn Dependencies
+		for _, dependency := range task.DependsOn {
+			if selected[dependency.Name] {
+				dependsOn = append(dependsOn, dependency)
+			}
+		}
+		task.DependsOn = dependsOn
+		result.Tasks = append(result.Tasks, task)
+	}
+	if len(result.Tasks) == 0 {
+		return LauncherConfig{}, fmt.Errorf("no tasks selected")
+	}
+	return result, nil
+}
+
+// GetLayeredStartupOrder 依照 depends_on 將任務分層，每一層只依賴於前面的層級
+func (lc *LauncherConfig) GetLayeredStartupOrder() ([][]string, error) {
+	var placed = make(map[string]bool)
+	var layers [][]string
+
+	for len(placed) < len(lc.Tasks) {
+		var layer []string
+		for _, task := range lc.Tasks {
+			if placed[task.Name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range task.DependsOn {
+				if !placed[dependency.Name] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, task.Name)
+			}
+		}
+		if len(layer) == 0 {
+			return nil, fmt.Errorf("unable to resolve startup order, check depends_on for missing or circular dependencies")
+		}
+		for _, name := range layer {
+			placed[name] = true
+		}
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// validateType 檢查 type，job 在結束時即完成，因此不支援健康檢查與持續重新啟動
+func validateType(task TaskConfig) error {
+	switch strings.ToLower(task.Type) {
+	case "", TaskTypeService:
+		if task.Required != nil {
+			return fmt.Errorf("task %s sets required, which only applies to tasks with type %s", task.Name, TaskTypeJob)
+		}
+		return nil
+	case TaskTypeJob:
+	default:
+		return fmt.Errorf("task %s has unknown type %q, expected one of: %s, %s", task.Name, task.Type, TaskTypeService, TaskTypeJob)
+	}
+	if task.Healthcheck.IsConfigured() {
+		return fmt.Errorf("task %s is a %s and cannot have a healthcheck, it is done when it exits", task.Name, TaskTypeJob)
+	}
+	if task.Liveness != nil {
+		return fmt.Errorf("task %s is a %s and cannot have a liveness check", task.Name, TaskTypeJob)
+	}
+	if task.Restart != nil {
+		switch task.Restart.Policy {
+		case "", RestartNo, RestartOnFailure:
+		default:
+			return fmt.Errorf("task %s is a %s, restart.policy must be %s or %s", task.Name, TaskTypeJob, RestartNo, RestartOnFailure)
+		}
+	}
+	return nil
+}
+
+func validateRestart(task TaskConfig) error {
+	if task.Restart == nil {
+		return nil
+	}
+	switch task.Restart.Policy {
+	case "", RestartNo, RestartOnFailure, RestartAlways, RestartUnlessStopped:
+	default:
+		return fmt.Errorf("task %s has unknown restart policy %q, expected one of: %s, %s, %s, %s",
+			task.Name, task.Restart.Policy, RestartNo, RestartOnFailure, RestartAlways, RestartUnlessStopped)
+	}
+	if task.Restart.MaxRetries < 0 {
+		return fmt.Errorf("task %s restart.max_retries must not be negative", task.Name)
+	}
+	if err := validateDuration(task.Name, "restart.backoff", task.Restart.Backoff); err != nil {
+		return err
+	}
+	return validateDuration(task.Name, "restart.max_backoff", task.Restart.MaxBackoff)
+}
+
+func validateHealthcheck(taskName string, key string, healthcheck *HealthCheckConfig) error {
+	if healthcheck.HTTP != nil {
+		if healthcheck.HTTP.URL == "" {
+			return fmt.Errorf("task %s %s.http.url is required", taskName, key)
+		}
+		if auth := healthcheck.HTTP.Auth; auth != nil && auth.Bearer != "" && (auth.Username != "" || auth.Password != "") {
+			return fmt.Errorf("task %s %s.http.auth accepts either bearer or username/password", taskName, key)
+		}
+		if healthcheck.HTTP.Expect != nil {
+			for _, status := range healthcheck.HTTP.Expect.Status {
+				if status < 100 || status > 599 {
+					return fmt.Errorf("task %s %s.http.expect.status has an invalid status code %d", taskName, key, status)
+				}
+			}
+			for i, expect := range healthcheck.HTTP.Expect.Json {
+				if err := validateJsonExpect(expect); err != nil {
+					return fmt.Errorf("task %s %s.http.expect.json[%d] %v", taskName, key, i, err)
+				}
+			}
+		}
+	}
+	if healthcheck.GRPC != nil && healthcheck.GRPC.Address == "" {
+		return fmt.Errorf("task %s %s.grpc.address is required", taskName, key)
+	}
+	if healthcheck.Command != nil && len(healthcheck.Command.Scripts) == 0 {
+		return fmt.Errorf("task %s %s.command.scripts is required", taskName, key)
+	}
+	if healthcheck.TCP != nil {
+		if port, err := strconv.Atoi(healthcheck.TCP.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("task %s %s.tcp.port must be a port number between 1 and 65535, got %q", taskName, key, healthcheck.TCP.Port)
+		}
+	}
+	if healthcheck.Socket != nil && healthcheck.Socket.Path == "" {
+		return fmt.Errorf("task %s %s.socket.path is required", taskName, key)
+	}
+	if healthcheck.File != nil {
+		if healthcheck.File.Path == "" {
+			return fmt.Errorf("task %s %s.file.path is required", taskName, key)
+		}
+		if healthcheck.File.MinSize < 0 {
+			return fmt.Errorf("task %s %s.file.min_size must not be negative", taskName, key)
+		}
+	}
+	if healthcheck.Log != nil {
+		if healthcheck.Log.Pattern == "" {
+			return fmt.Errorf("task %s %s.log.pattern is required", taskName, key)
+		}
+		if _, err := regexp.Compile(healthcheck.Log.Pattern); err != nil {
+			return fmt.Errorf("task %s %s.log.pattern is not a valid regular expression: %v", taskName, key, err)
+		}
+		switch healthcheck.Log.Stream {
+		case "", LogStreamStdout, LogStreamStderr, LogStreamBoth:
+		default:
+			return fmt.Errorf("task %s %s.log.stream must be one of: %s, %s, %s", taskName, key, LogStreamStdout, LogStreamStderr, LogStreamBoth)
+		}
+	}
+	if healthcheck.Frequency != nil {
+		for _, duration := range [][2]string{
+			{"interval", healthcheck.Frequency.Interval},
+			{"timeout", healthcheck.Frequency.Timeout},
+			{"delay", healthcheck.Frequency.Delay},
+		} {
+			if err := validateDuration(taskName, key+".frequency."+duration[0], duration[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateLiveness(task TaskConfig) error {
+	if task.Liveness == nil {
+		return nil
+	}
+	if err := validateHealthcheck(task.Name, "liveness", &task.Liveness.HealthCheckConfig); err != nil {
+		return err
+	}
+	if task.Liveness.Log != nil {
+		return fmt.Errorf("task %s liveness.log is not supported, the log pattern only signals readiness", task.Name)
+	}
+	if !task.Liveness.IsConfigured() {
+		return fmt.Errorf("task %s liveness requires at least one check", task.Name)
+	}
+	switch task.Liveness.Action {
+	case "", LivenessActionNone, LivenessActionRestart, LivenessActionStopDependents:
+	default:
+		return fmt.Errorf("task %s has unknown liveness.action %q, expected one of: %s, %s, %s",
+			task.Name, task.Liveness.Action, LivenessActionNone, LivenessActionRestart, LivenessActionStopDependents)
+	}
+	return nil
+}
+
+func validateStop(task TaskConfig) error {
+	if task.StopSignal != "" && !slices.Contains(StopSignals, NormalizeSignalName(task.StopSignal)) {
+		return fmt.Errorf("task %s has unknown stop_signal %q, expected one of: %s",
+			task.Name, task.StopSignal, strings.Join(StopSignals, ", "))
+	}
+	return validateDuration(task.Name, "stop_timeout", task.StopTimeout)
+}
+
+func validateDuration(taskName string, key string, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return fmt.Errorf("task %s %s is not a valid duration: %v", taskName, key, err)
+	}
+	return nil
+}
+
 func checkCycleDFS(taskName string, tasks map[string]TaskConfig, taskStates map[string]TaskConfigCheck) error {
 
 	taskStates[taskName] = Visiting
 
-	for _, dependencyName := range tasks[taskName].DependsOn {
+	for _, dependency := range tasks[taskName].DependsOn {
+		var dependencyName = dependency.Name
 		state := taskStates[dependencyName]
 		if state == Visiting {
 			return fmt.Errorf("circular dependency detected: %s -> %s", taskName, dependencyName)
@@ -90,3 +389,36 @@ func checkCycleDFS(taskName string, tasks map[string]TaskConfig, taskStates map[
 	taskStates[taskName] = Visited
 	return nil
 }
+
+// validateJsonExpect 檢查 jsonpath 斷言的路徑、比較方式與 value 的型別
+func validateJsonExpect(expect HttpCheckExpectJson) error {
+	if expect.Jsonpath == "" {
+		return fmt.Errorf("jsonpath is required")
+	}
+	if _, err := jsonpath.Compile(expect.Jsonpath); err != nil {
+		return fmt.Errorf("jsonpath %q is invalid: %v", expect.Jsonpath, err)
+	}
+	var operator = expect.GetOperator()
+	if !slices.Contains(JsonOperators, operator) {
+		return fmt.Errorf("operator %q is not supported, use one of %s", expect.Operator, strings.Join(JsonOperators, ", "))
+	}
+	switch operator {
+	case JsonOperatorGt, JsonOperatorLt:
+		if _, err := strconv.ParseFloat(fmt.Sprint(expect.Value), 64); err != nil {
+			return fmt.Errorf("operator %s requires a number value", operator)
+		}
+	case JsonOperatorRegex:
+		pattern, ok := expect.Value.(string)
+		if !ok {
+			return fmt.Errorf("operator regex requires a string value")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("value is not a valid regex: %v", err)
+		}
+	case JsonOperatorIn:
+		if _, ok := expect.Value.([]any); !ok {
+			return fmt.Errorf("operator in requires a list value")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package procedure
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CheckHealth 執行一次健康檢查，第二個回傳值表示是否有設定健康檢查。
+// log 檢查只在啟動時比對一次輸出，無法在其他程序中重新執行，因此會被略過
+func (t *Task) CheckHealth() (bool, bool) {
+	var healthcheck = t.Healthcheck
+	healthcheck.Log = nil
+	if !healthcheck.IsConfigured() {
+		return false, false
+	}
+	if t.logger == nil {
+		t.logger = &utils.SharedAppLogger
+	}
+	return t.runProbes(&healthcheck), true
+}
+
+func (t *Task) isHealthCheckConfigured() bool {
+	return t.Healthcheck.IsConfigured()
+}
+
+const logWatchInterval = 100 * time.Millisecond
+
+func (t *Task) doHealthCheck() bool {
+
+	if !t.isHealthCheckConfigured() {
+		return true
+	}
+
+	if !t.runProbes(&t.Healthcheck) {
+		return false
+	}
+
+	if t.Healthcheck.Log != nil && !t.logMatched.Load() {
+		return false
+	}
+
+	return true
+
+}
+
+// runProbes 執行 healthcheck 或 liveness 中設定的檢查，全部成功時回傳 true
+func (t *Task) runProbes(healthcheck *config.HealthCheckConfig) bool {
+	var timeout = healthCheckDefaultTimeout
+
+	if healthcheck.Frequency != nil && healthcheck.Frequency.Timeout != "" {
+		timeout, _ = time.ParseDuration(healthcheck.Frequency.Timeout)
+	}
+
+	if healthcheck.HTTP != nil && !t.checkHTTP(healthcheck.HTTP, timeout) {
+		return false
+	}
+
+	if healthcheck.GRPC != nil && !t.checkGRPC(healthcheck.GRPC, timeout) {
+		return false
+	}
+
+	if healthcheck.Command != nil && !t.checkCommand(healthcheck.Command, timeout) {
+		return false
+	}
+
+	if healthcheck.TCP != nil && !t.checkTCP(healthcheck.TCP, timeout) {
+		return false
+	}
+
+	if healthcheck.Socket != nil && !t.checkSocket(healthcheck.Socket, timeout) {
+		return false
+	}
+
+	if healthcheck.File != nil && !t.checkFile(healthcheck.File) {
+		return false
+	}
+
+	return true
+}
+
+func (t *Task) checkHTTP(check *config.HTTPCheck, timeout time.Duration) bool {
+	client, err := newHTTPClient(check, timeout)
+	if err != nil {
+		t.logger.Warn(fmt.Sprintf("Health check http client: %v", err))
+		return false
+	}
+	defer client.CloseIdleConnections()
+
+	var method = http.MethodGet
+	if check.Method != "" {
+		method = strings.ToUpper(check.Method)
+	}
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+	req, err := http.NewRequest(method, check.RequestURL(), body)
+	if err != nil {
+		t.logger.Warn(fmt.Sprintf("Health check request: %v", err))
+		return false
+	}
+	for key, value := range check.Headers {
+		req.Header.Set(key, value)
+	}
+	if check.Auth != nil {
+		if check.Auth.Bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+check.Auth.Bearer)
+		} else if check.Auth.Username != "" {
+			req.SetBasicAuth(check.Auth.Username, check.Auth.Password)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.logger.Debug(fmt.Sprintf("Health check http: %v", err))
+		return false
+	}
+	defer resp.Body.Close()
+
+	if !expectedStatus(check.Expect, resp.StatusCode) {
+		t.logger.Debug(fmt.Sprintf("Health check status code: %d", resp.StatusCode))
+		return false
+	}
+
+	if check.Expect != nil && (len(check.Expect.Json) > 0 || check.Expect.Plain != nil) {
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.logger.Debug(fmt.Sprintf("Health check body error: %v", err))
+			return false
+		}
+
+		if len(check.Expect.Json) > 0 {
+			var contentType = resp.Header.Get("Content-Type")
+			if !strings.Contains(contentType, "application/json") {
+				t.logger.Debug(fmt.Sprintf("Health check contenttype: %s", contentType))
+				return false
+			}
+
+			var jsonResp interface{}
+			if err = json.Unmarshal(bodyBytes, &jsonResp); err != nil {
+				t.logger.Warn(fmt.Sprintf("Health check json: %v", err))
+				return false
+			}
+
+			for _, expect := range check.Expect.Json {
+				if ok, reason := assertJson(jsonResp, expect); !ok {
+					t.logger.Warn(fmt.Sprintf("Health check json: %s", reason))
+					return false
+				}
+			}
+		}
+
+		if check.Expect.Plain != nil {
+			if check.Expect.Plain.Contains == "" {
+				return false
+			}
+			if !strings.Contains(string(bodyBytes), check.Expect.Plain.Contains) {
+				t.logger.Debug(fmt.Sprintf("Health check body does not contain %q", check.Expect.Plain.Contains))
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// expectedStatus 檢查狀態碼是否在 expect.status 中，未設定時接受所有 2xx
+func expectedStatus(expect *config.HttpCheckExpect, statusCode int) bool {
+	if expect == nil || len(expect.Status) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(expect.Status, statusCode)
+}
+
+// newHTTPClient 依照 TLS 設定建立 http client
+func newHTTPClient(check *config.HTTPCheck, timeout time.Duration) (*http.Client, error) {
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	if check.InsecureSkipVerify || check.CAFile != "" {
+		tlsConfig, err := newTLSConfig(check.InsecureSkipVerify, check.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if check.UnixSocket != "" {
+		// every request is sent to the socket, the host in the url is only used for the Host header
+		var dialer = &net.Dialer{Timeout: timeout}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", check.UnixSocket)
+		}
+		transport.Proxy = nil
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// newTLSConfig 建立 TLS 設定，ca_file 中的憑證會加入系統的 CA
+func newTLSConfig(insecureSkipVerify bool, caFile string) (*tls.Config, error) {
+	var tlsConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// checkGRPC 呼叫 grpc.health.v1.Health/Check，回應 SERVING 時視為健康
+func (t *Task) checkGRPC(check *config.GRPCCheck, timeout time.Duration) bool {
+	var creds = insecure.NewCredentials()
+	if check.TLS || check.InsecureSkipVerify || check.CAFile != "" {
+		tlsConfig, err := newTLSConfig(check.InsecureSkipVerify, check.CAFile)
+		if err != nil {
+			t.logger.Warn(fmt.Sprintf("Health check grpc tls: %v", err))
+			return false
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(check.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.logger.Warn(fmt.Sprintf("Health check grpc client: %v", err))
+		return false
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: check.Service})
+	if err != nil {
+		t.logger.Debug(fmt.Sprintf("Health check grpc %s: %v", check.Address, err))
+		return false
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.logger.Debug(fmt.Sprintf("Health check grpc status: %s", resp.GetStatus()))
+		return false
+	}
+	return true
+}
+
+func (t *Task) checkCommand(check *config.CommandCheck, timeout time.Duration) bool {
+	if len(check.Scripts) > 0 {
+		var cmd = check.Scripts[0]
+		var args = check.Scripts[1:]
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		var process = exec.CommandContext(ctx, cmd, args...)
+		defer cancel()
+
+		if err := process.Start(); err != nil {
+			return false
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- process.Wait()
+		}()
+		select {
+		case <-ctx.Done():
+			return false
+		case err := <-done:
+			if err != nil {
+				return false
+			}
+		}
+	} else {
+		return false
+	}
+	return true
+}
+
+// checkTCP 確認可以在 timeout 內建立 TCP 連線
+func (t *Task) checkTCP(check *config.TCPCheck, timeout time.Duration) bool {
+	var address = net.JoinHostPort(check.Address(), check.Port)
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		t.logger.Debug(fmt.Sprintf("Health check tcp %s: %v", address, err))
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func (t *Task) checkSocket(check *config.SocketCheck, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("unix", check.Path, timeout)
+	if err != nil {
+		t.logger.Debug(fmt.Sprintf("Health check socket %s: %v", check.Path, err))
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// checkFile 檢查檔案是否存在，並依設定檢查大小以及是否在任務啟動後寫入
+func (t *Task) checkFile(check *config.FileCheck) bool {
+	var path = check.Path
+	if !filepath.IsAbs(path) && t.BaseDir != "" {
+		path = filepath.Join(t.BaseDir, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.logger.Debug(fmt.Sprintf("Health check file: %v", err))
+		return false
+	}
+	if check.MinSize > 0 && info.Size() < check.MinSize {
+		t.logger.Debug(fmt.Sprintf("Health check file %s size %d is less than %d", path, info.Size(), check.MinSize))
+		return false
+	}
+	if check.NewerThanStart && info.ModTime().Before(t.startedAt) {
+		t.logger.Debug(fmt.Sprintf("Health check file %s was not written since the task started", path))
+		return false
+	}
+	return true
+}
+
+// watchOutput 比對任務輸出的每一行，符合 healthcheck.log.pattern 時視為已就緒
+func (t *Task) watchOutput(stream string, line string) {
+	var check = t.Healthcheck.Log
+	if check == nil || t.logPattern == nil || t.logMatched.Load() {
+		return
+	}
+	if check.Stream != "" && check.Stream != config.LogStreamBoth && check.Stream != stream {
+		return
+	}
+	if t.logPattern.MatchString(line) {
+		t.logMatched.Store(true)
+	}
+}
+
+// tailOutput 在 detach 模式下從 log 檔案讀取任務輸出，stdout 與 stderr 寫入同一個檔案因此無法區分
+func (t *Task) tailOutput(file string, offset int64, exited <-chan struct{}) {
+	output, err := os.Open(file)
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	defer output.Close()
+	if _, err = output.Seek(offset, io.SeekStart); err != nil {
+		t.logger.Error(err)
+		return
+	}
+
+	var reader = bufio.NewReader(output)
+	var partial string
+	for !t.logMatched.Load() {
+		chunk, err := reader.ReadString('\n')
+		if err == nil {
+			t.watchOutput(config.LogStreamBoth, strings.TrimRight(partial+chunk, "\r\n"))
+			partial = ""
+			continue
+		}
+		partial += chunk
+		select {
+		case <-exited:
+			return
+		case <-time.After(logWatchInterval):
+		}
+	}
+}
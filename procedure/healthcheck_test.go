@@ -0,0 +1,84 @@
+package procedure
+
+import (
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"github.com/vulcanshen-tpi/task-compose/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"testing"
+	"time"
+)
+
+// startHealthServer 啟動只提供 grpc.health.v1.Health 的 in-process server，回傳監聽的位址
+func startHealthServer(t *testing.T) (string, *health.Server) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var healthServer = health.NewServer()
+	var server = grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), healthServer
+}
+
+func TestCheckGRPC(t *testing.T) {
+	address, healthServer := startHealthServer(t)
+	healthServer.SetServingStatus("api", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("worker", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	var task = &Task{Name: "grpc", logger: &utils.SharedAppLogger}
+	var tests = []struct {
+		name    string
+		service string
+		want    bool
+	}{
+		{name: "overall server", service: "", want: true},
+		{name: "serving", service: "api", want: true},
+		{name: "not serving", service: "worker", want: false},
+		{name: "unknown service", service: "missing", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var check = &config.GRPCCheck{Address: address, Service: test.service}
+			if got := task.checkGRPC(check, time.Second); got != test.want {
+				t.Errorf("checkGRPC(%q) = %t, want %t", test.service, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckGRPCStatusChange(t *testing.T) {
+	address, healthServer := startHealthServer(t)
+	healthServer.SetServingStatus("api", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	var task = &Task{Name: "grpc", logger: &utils.SharedAppLogger}
+	var check = &config.GRPCCheck{Address: address, Service: "api"}
+	if task.checkGRPC(check, time.Second) {
+		t.Fatal("checkGRPC reported healthy before the service was serving")
+	}
+	healthServer.SetServingStatus("api", healthpb.HealthCheckResponse_SERVING)
+	if !task.checkGRPC(check, time.Second) {
+		t.Fatal("checkGRPC reported unhealthy after the service started serving")
+	}
+}
+
+func TestCheckGRPCUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var address = listener.Addr().String()
+	_ = listener.Close()
+
+	var task = &Task{Name: "grpc", logger: &utils.SharedAppLogger}
+	if task.checkGRPC(&config.GRPCCheck{Address: address}, 200*time.Millisecond) {
+		t.Fatal("checkGRPC reported healthy without a server")
+	}
+}
@@ -0,0 +1,135 @@
+package procedure
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/oliveagle/jsonpath"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// assertJson 以 jsonpath 取值並依照比較方式檢查，失敗時回傳原因
+func assertJson(document any, expect config.HttpCheckExpectJson) (bool, string) {
+	var operator = expect.GetOperator()
+	actual, err := jsonpath.JsonPathLookup(document, expect.Jsonpath)
+	if err != nil {
+		if operator == config.JsonOperatorExists {
+			return false, fmt.Sprintf("%s does not exist", expect.Jsonpath)
+		}
+		return false, fmt.Sprintf("%s lookup failed: %v", expect.Jsonpath, err)
+	}
+
+	switch operator {
+	case config.JsonOperatorExists:
+		return true, ""
+	case config.JsonOperatorEquals:
+		if jsonEquals(actual, expect.Value) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is %s, expected %s", expect.Jsonpath, jsonText(actual), jsonText(expect.Value))
+	case config.JsonOperatorNotEquals:
+		if !jsonEquals(actual, expect.Value) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is %s, expected a different value", expect.Jsonpath, jsonText(actual))
+	case config.JsonOperatorGt, config.JsonOperatorLt:
+		number, ok := jsonNumber(actual)
+		if !ok {
+			return false, fmt.Sprintf("%s is %s, expected a number", expect.Jsonpath, jsonText(actual))
+		}
+		limit, _ := jsonNumber(expect.Value)
+		if (operator == config.JsonOperatorGt && number > limit) || (operator == config.JsonOperatorLt && number < limit) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is %s, expected %s %s", expect.Jsonpath, jsonText(actual), operator, jsonText(expect.Value))
+	case config.JsonOperatorRegex:
+		pattern, err := regexp.Compile(fmt.Sprint(expect.Value))
+		if err != nil {
+			return false, fmt.Sprintf("invalid regex %q: %v", expect.Value, err)
+		}
+		var text, isString = actual.(string)
+		if !isString {
+			text = jsonText(actual)
+		}
+		if pattern.MatchString(text) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is %s, expected to match %s", expect.Jsonpath, jsonText(actual), pattern)
+	case config.JsonOperatorIn:
+		candidates, _ := expect.Value.([]any)
+		for _, candidate := range candidates {
+			if jsonEquals(actual, candidate) {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("%s is %s, expected one of %s", expect.Jsonpath, jsonText(actual), jsonText(expect.Value))
+	}
+	return false, fmt.Sprintf("operator %q is not supported", expect.Operator)
+}
+
+// jsonEquals 依照 json 的型別比較，設定值為字串時 (例如變數替換的結果) 轉換成對應的型別
+func jsonEquals(actual, expected any) bool {
+	switch value := actual.(type) {
+	case nil:
+		return expected == nil || expected == "null"
+	case bool:
+		switch want := expected.(type) {
+		case bool:
+			return value == want
+		case string:
+			parsed, err := strconv.ParseBool(want)
+			return err == nil && value == parsed
+		}
+		return false
+	case float64:
+		want, ok := jsonNumber(expected)
+		return ok && value == want
+	case string:
+		if want, ok := expected.(string); ok {
+			return value == want
+		}
+		return false
+	}
+	return reflect.DeepEqual(actual, normalizeJson(expected))
+}
+
+// jsonNumber 將 yaml 或 json 中的數字 (以及數字字串) 轉換為 float64
+func jsonNumber(value any) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	case float64:
+		return number, true
+	case string:
+		parsed, err := strconv.ParseFloat(number, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// normalizeJson 將 yaml 解析出的 list 與 map 轉換成 json 解析的型別以便比較
+func normalizeJson(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func jsonText(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,131 @@
+package procedure
+
+import (
+	"encoding/json"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"testing"
+)
+
+const jsonAssertDocument = `{
+	"status": "UP",
+	"ready": true,
+	"degraded": false,
+	"error": null,
+	"version": "1.10.2",
+	"uptime": 42.5,
+	"replicas": 3,
+	"tags": ["a", "b"],
+	"db": {"status": "UP", "latency": 12},
+	"checks": [{"name": "disk", "ok": true}]
+}`
+
+func TestAssertJson(t *testing.T) {
+	var document any
+	if err := json.Unmarshal([]byte(jsonAssertDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+	// value is the value as decoded from yaml, operator "" uses the default of GetOperator
+	var tests = []struct {
+		name     string
+		jsonpath string
+		operator string
+		value    any
+		valueSet bool
+		want     bool
+	}{
+		{name: "exists", jsonpath: "$.status", want: true},
+		{name: "exists for null", jsonpath: "$.error", want: true},
+		{name: "missing path", jsonpath: "$.missing", want: false},
+		{name: "explicit exists", jsonpath: "$.db.status", operator: "exists", want: true},
+
+		{name: "string equals", jsonpath: "$.status", value: "UP", valueSet: true, want: true},
+		{name: "string differs", jsonpath: "$.status", value: "DOWN", valueSet: true, want: false},
+		{name: "string is not a number", jsonpath: "$.version", value: 1.1, valueSet: true, want: false},
+		{name: "nested", jsonpath: "$.db.status", value: "UP", valueSet: true, want: true},
+		{name: "operator is case insensitive", jsonpath: "$.status", operator: "EQUALS", value: "UP", valueSet: true, want: true},
+
+		{name: "bool", jsonpath: "$.ready", value: true, valueSet: true, want: true},
+		{name: "bool false", jsonpath: "$.degraded", value: false, valueSet: true, want: true},
+		{name: "bool differs", jsonpath: "$.ready", value: false, valueSet: true, want: false},
+		{name: "bool from string", jsonpath: "$.ready", value: "true", valueSet: true, want: true},
+		{name: "bool is not a string", jsonpath: "$.ready", value: "yes", valueSet: true, want: false},
+
+		{name: "int", jsonpath: "$.replicas", value: 3, valueSet: true, want: true},
+		{name: "float", jsonpath: "$.uptime", value: 42.5, valueSet: true, want: true},
+		{name: "number from string", jsonpath: "$.replicas", value: "3", valueSet: true, want: true},
+		{name: "number differs", jsonpath: "$.replicas", value: 4, valueSet: true, want: false},
+
+		{name: "null", jsonpath: "$.error", value: nil, valueSet: true, want: true},
+		{name: "null from string", jsonpath: "$.error", value: "null", valueSet: true, want: true},
+		{name: "null differs", jsonpath: "$.status", value: nil, valueSet: true, want: false},
+		{name: "unset value only checks existence", jsonpath: "$.status", value: nil, valueSet: false, want: true},
+
+		{name: "list", jsonpath: "$.tags", value: []any{"a", "b"}, valueSet: true, want: true},
+		{name: "list differs", jsonpath: "$.tags", value: []any{"b", "a"}, valueSet: true, want: false},
+		{name: "object", jsonpath: "$.db", value: map[string]any{"status": "UP", "latency": 12}, valueSet: true, want: true},
+
+		{name: "not equals", jsonpath: "$.status", operator: "not_equals", value: "DOWN", valueSet: true, want: true},
+		{name: "not equals fails", jsonpath: "$.status", operator: "not_equals", value: "UP", valueSet: true, want: false},
+		{name: "not equals null", jsonpath: "$.error", operator: "not_equals", value: nil, valueSet: true, want: false},
+
+		{name: "gt", jsonpath: "$.uptime", operator: "gt", value: 10, valueSet: true, want: true},
+		{name: "gt equal", jsonpath: "$.replicas", operator: "gt", value: 3, valueSet: true, want: false},
+		{name: "lt", jsonpath: "$.db.latency", operator: "lt", value: "100", valueSet: true, want: true},
+		{name: "lt fails", jsonpath: "$.db.latency", operator: "lt", value: 12, valueSet: true, want: false},
+		{name: "gt on a string", jsonpath: "$.version", operator: "gt", value: 1, valueSet: true, want: false},
+
+		{name: "regex", jsonpath: "$.version", operator: "regex", value: `^1\.\d+\.\d+$`, valueSet: true, want: true},
+		{name: "regex fails", jsonpath: "$.version", operator: "regex", value: `^2\.`, valueSet: true, want: false},
+		{name: "regex on a number", jsonpath: "$.replicas", operator: "regex", value: `^\d+$`, valueSet: true, want: true},
+		{name: "invalid regex", jsonpath: "$.version", operator: "regex", value: `(`, valueSet: true, want: false},
+
+		{name: "in", jsonpath: "$.status", operator: "in", value: []any{"UP", "DEGRADED"}, valueSet: true, want: true},
+		{name: "in fails", jsonpath: "$.status", operator: "in", value: []any{"DOWN"}, valueSet: true, want: false},
+		{name: "in with numbers", jsonpath: "$.replicas", operator: "in", value: []any{1, 3}, valueSet: true, want: true},
+		{name: "in without a list", jsonpath: "$.status", operator: "in", value: "UP", valueSet: true, want: false},
+
+		{name: "filter expression", jsonpath: "$.checks[0].ok", value: true, valueSet: true, want: true},
+		{name: "unknown operator", jsonpath: "$.status", operator: "contains", value: "U", valueSet: true, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var expect = config.HttpCheckExpectJson{
+				Jsonpath: test.jsonpath,
+				Operator: test.operator,
+				Value:    test.value,
+				ValueSet: test.valueSet,
+			}
+			got, reason := assertJson(document, expect)
+			if got != test.want {
+				t.Errorf("assertJson(%s %s %v) = %t (%s), want %t", test.jsonpath, expect.GetOperator(), test.value, got, reason, test.want)
+			}
+			if !got && reason == "" {
+				t.Errorf("assertJson(%s) failed without a reason", test.jsonpath)
+			}
+		})
+	}
+}
+
+func TestAssertJsonReason(t *testing.T) {
+	var document any
+	if err := json.Unmarshal([]byte(jsonAssertDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		expect config.HttpCheckExpectJson
+		want   string
+	}{
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.missing"}, want: "$.missing does not exist"},
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.status", Value: "DOWN", ValueSet: true}, want: `$.status is "UP", expected "DOWN"`},
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.status", Value: nil, ValueSet: true}, want: `$.status is "UP", expected null`},
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.replicas", Operator: "gt", Value: 5, ValueSet: true}, want: "$.replicas is 3, expected gt 5"},
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.version", Operator: "lt", Value: 5, ValueSet: true}, want: `$.version is "1.10.2", expected a number`},
+		{expect: config.HttpCheckExpectJson{Jsonpath: "$.status", Operator: "in", Value: []any{"DOWN"}, ValueSet: true}, want: `$.status is "UP", expected one of ["DOWN"]`},
+	}
+	for _, test := range tests {
+		_, reason := assertJson(document, test.expect)
+		if reason != test.want {
+			t.Errorf("assertJson(%s) reason = %q, want %q", test.expect.Jsonpath, reason, test.want)
+		}
+	}
+}
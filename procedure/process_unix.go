@@ -0,0 +1,73 @@
+//go:build !windows
+
+package procedure
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var signals = map[string]os.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+func lookupSignal(name string) (os.Signal, bool) {
+	sig, ok := signals[name]
+	return sig, ok
+}
+
+// exitCode 回傳程序的結束代碼，被訊號終止時依照慣例為 128 + signal number
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+// setProcessGroup 讓任務在獨立的 process group 中執行，停止時可以一併通知所有子程序
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func processGroupID(pid int) int {
+	pgid, err := syscall.Getpgid(pid)
+	if err != nil {
+		return 0
+	}
+	return pgid
+}
+
+// ownGroup 避免對 task-compose 本身所在的 process group 送出訊號
+func ownGroup(pgid int) bool {
+	return pgid <= 0 || pgid == syscall.Getpgrp()
+}
+
+func signalProcess(process *os.Process, pgid int, sig os.Signal) error {
+	if ownGroup(pgid) {
+		return process.Signal(sig)
+	}
+	return syscall.Kill(-pgid, sig.(syscall.Signal))
+}
+
+func killProcess(process *os.Process, pgid int) error {
+	return signalProcess(process, pgid, syscall.SIGKILL)
+}
+
+func groupAlive(pgid int) bool {
+	if ownGroup(pgid) {
+		return false
+	}
+	return syscall.Kill(-pgid, syscall.Signal(0)) == nil
+}
+
+// ProcessAlive 以 signal 0 檢查程序或其 process group 是否仍存在
+func ProcessAlive(process *os.Process, pgid int) bool {
+	return process.Signal(syscall.Signal(0)) == nil || groupAlive(pgid)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package procedure
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+const stillActive = 259
+
+// windows only supports killing a process, every other signal falls back to kill
+func lookupSignal(name string) (os.Signal, bool) {
+	return nil, false
+}
+
+// exitCode 回傳程序的結束代碼，windows 上被終止的程序也有結束代碼
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+// setProcessGroup 讓任務在獨立的 process group 中執行
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// the id of a new process group is the pid of its root process
+func processGroupID(pid int) int {
+	return pid
+}
+
+func signalProcess(process *os.Process, pgid int, sig os.Signal) error {
+	return process.Signal(sig)
+}
+
+// killProcess 以 taskkill 結束整個程序樹，失敗時只結束程序本身
+func killProcess(process *os.Process, pgid int) error {
+	if pgid > 0 {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pgid)).Run(); err == nil {
+			return nil
+		}
+	}
+	return process.Kill()
+}
+
+func groupAlive(pgid int) bool {
+	return false
+}
+
+// ProcessAlive 以 process exit code 檢查程序是否仍在執行
+func ProcessAlive(process *os.Process, pgid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(process.Pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err = syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
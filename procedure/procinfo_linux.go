@@ -0,0 +1,33 @@
+//go:build linux
+
+package procedure
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTicks 讀取 /proc/<pid>/stat 中的 starttime 欄位，用來辨識 PID 是否被重複使用
+func processStartTicks(pid int) (uint64, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, false
+	}
+	// the command name may contain spaces, fields are counted after its closing parenthesis
+	var stat = string(data)
+	var end = strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+	var fields = strings.Fields(stat[end+1:])
+	// starttime is field 22, the fields after the command name start at field 3
+	if len(fields) < 20 {
+		return 0, false
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ticks, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package procedure
+
+// processStartTicks is only available through /proc on linux
+func processStartTicks(pid int) (uint64, bool) {
+	return 0, false
+}
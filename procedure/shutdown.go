@@ -0,0 +1,67 @@
+package procedure
+
+import (
+	"fmt"
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"os"
+	"time"
+)
+
+const (
+	stopDefaultSignal  = "SIGTERM"
+	stopDefaultTimeout = 10 * time.Second
+	stopPollInterval   = 100 * time.Millisecond
+)
+
+// ParseStopTimeout 解析 stop_timeout，未設定或格式錯誤時使用預設值
+func ParseStopTimeout(value string) time.Duration {
+	if value == "" {
+		return stopDefaultTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return stopDefaultTimeout
+	}
+	return timeout
+}
+
+// StopProcess 先對程序的 process group 送出 stop signal，等待程序在 timeout 內結束，逾時則升級為 SIGKILL。
+// pgid 為 0 時只通知程序本身，alive 用來判斷程序是否仍在執行，回傳的訊息描述實際採用的停止方式。
+func StopProcess(process *os.Process, pgid int, signalName string, timeout time.Duration, alive func() bool) (string, error) {
+	if !alive() {
+		return fmt.Sprintf("Process already exited PID: %d", process.Pid), nil
+	}
+
+	signalName = config.NormalizeSignalName(signalName)
+	if signalName == "" {
+		signalName = stopDefaultSignal
+	}
+
+	var message = fmt.Sprintf("Killed with SIGKILL PID: %d", process.Pid)
+	if signalName != "SIGKILL" {
+		message = fmt.Sprintf("Killed (%s unsupported) PID: %d", signalName, process.Pid)
+		if sig, ok := lookupSignal(signalName); ok && signalProcess(process, pgid, sig) == nil {
+			if waitForExit(alive, timeout) {
+				return fmt.Sprintf("Stopped with %s PID: %d", signalName, process.Pid), nil
+			}
+			message = fmt.Sprintf("Killed after %s stop timeout PID: %d", timeout, process.Pid)
+		}
+	}
+
+	if err := killProcess(process, pgid); err != nil {
+		return "", err
+	}
+	waitForExit(alive, stopDefaultTimeout)
+	return message, nil
+}
+
+func waitForExit(alive func() bool, timeout time.Duration) bool {
+	var deadline = time.Now().Add(timeout)
+	for alive() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+	return true
+}
@@ -3,10 +3,12 @@ package procedure
 import (
 	"github.com/chelnak/ysmrr"
 	"github.com/vulcanshen-tpi/task-compose/app"
+	"sync"
 )
 
 type SpinnerAgent struct {
 	sm       ysmrr.SpinnerManager
+	lock     sync.Mutex
 	spinners map[string]*ysmrr.Spinner
 }
 
@@ -19,10 +21,8 @@ func InitializeSpinnerAgent() {
 		return
 	}
 	var spinnerManager = ysmrr.NewSpinnerManager()
-	TaskSpinner = SpinnerAgent{
-		sm:       spinnerManager,
-		spinners: make(map[string]*ysmrr.Spinner),
-	}
+	TaskSpinner.sm = spinnerManager
+	TaskSpinner.spinners = make(map[string]*ysmrr.Spinner)
 }
 
 func StartSpinnerAgent() {
@@ -50,6 +50,9 @@ func (sa *SpinnerAgent) RegisterSpinner(name string, prefix string, defaultMessa
 	var spinner = sa.sm.AddSpinner(name)
 	spinner.UpdatePrefix(prefix)
 	spinner.UpdateMessage(defaultMessage)
+	// tasks register their spinners from their own goroutines
+	sa.lock.Lock()
+	defer sa.lock.Unlock()
 	sa.spinners[name] = spinner
 }
 
@@ -57,6 +60,8 @@ func (sa *SpinnerAgent) GetSpinner(name string) (*ysmrr.Spinner, bool) {
 	if !app.DetachMode {
 		return nil, false
 	}
+	sa.lock.Lock()
+	defer sa.lock.Unlock()
 	var spinner, ok = sa.spinners[name]
 	return spinner, ok
 }
@@ -0,0 +1,109 @@
+package procedure
+
+// TaskState 是任務在 up 期間的狀態，只能在持有 Task.lock 時透過 setState 變更
+type TaskState int
+
+const (
+	StatePending   TaskState = iota // 尚未開始
+	StateWaiting                    // 等待依賴的任務滿足條件
+	StateStarting                   // 程序啟動中，job 在結束前都維持此狀態
+	StateProbing                    // 執行健康檢查中
+	StateHealthy                    // 健康檢查成功
+	StateUnhealthy                  // 健康檢查或 liveness 檢查失敗
+	StateExited                     // 程序結束且不會再重新啟動
+	StateStopped                    // 被停止，或因依賴失敗而沒有啟動
+)
+
+var stateNames = map[TaskState]string{
+	StatePending:   "pending",
+	StateWaiting:   "waiting",
+	StateStarting:  "starting",
+	StateProbing:   "probing",
+	StateHealthy:   "healthy",
+	StateUnhealthy: "unhealthy",
+	StateExited:    "exited",
+	StateStopped:   "stopped",
+}
+
+func (s TaskState) String() string {
+	return stateNames[s]
+}
+
+// Final 判斷狀態是否不會再改變
+func (s TaskState) Final() bool {
+	return s == StateExited || s == StateStopped
+}
+
+// State 回傳任務目前的狀態
+func (t *Task) State() TaskState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state
+}
+
+// Failed 判斷任務是否沒有通過啟動時的健康檢查，或因依賴失敗而沒有啟動
+func (t *Task) Failed() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.failed
+}
+
+// Ready 判斷任務是否已經啟動完成: service 曾經通過健康檢查，job 已經成功結束
+func (t *Task) Ready() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.Job {
+		return t.state == StateExited && t.exitState != nil && t.exitState.Success()
+	}
+	return t.ready
+}
+
+// setState 變更狀態並喚醒等待此任務的任務，讓它們立即重新檢查依賴條件
+func (t *Task) setState(state TaskState) {
+	t.lock.Lock()
+	if t.state.Final() {
+		// a finished task keeps its final state, e.g. exited is not replaced by stopped on shutdown
+		t.lock.Unlock()
+		return
+	}
+	t.state = state
+	if state == StateHealthy {
+		t.ready = true
+	}
+	t.lock.Unlock()
+
+	for _, dependent := range t.dependents {
+		dependent.wakeUp()
+	}
+}
+
+// wakeUp 通知任務有依賴的狀態改變，通知不會阻塞，尚未處理的通知只保留一個
+func (t *Task) wakeUp() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dependencyState 在同一次鎖定中取得依賴條件需要的狀態，ready 在任務曾經通過健康檢查後不會再變回 false
+func (t *Task) dependencyState() (state TaskState, started bool, ready bool, success bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state, t.started, t.ready, t.exitState != nil && t.exitState.Success()
+}
+
+// transition 只在目前狀態為 from 時變更為 to，避免覆蓋其他 goroutine 已經變更的狀態
+func (t *Task) transition(from TaskState, to TaskState) bool {
+	t.lock.Lock()
+	if t.state != from {
+		t.lock.Unlock()
+		return false
+	}
+	t.state = to
+	t.lock.Unlock()
+
+	for _, dependent := range t.dependents {
+		dependent.wakeUp()
+	}
+	return true
+}
@@ -2,42 +2,102 @@ package procedure
 
 import (
 	"bufio"
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/vulcanshen-tpi/task-compose/app"
 	"github.com/vulcanshen-tpi/task-compose/config"
 	"github.com/vulcanshen-tpi/task-compose/utils"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/oliveagle/jsonpath"
 	"gopkg.in/yaml.v3"
 )
 
 type Task struct {
-	Name        string
-	BaseDir     string
-	Envs        []string
-	Executable  string
-	Args        []string
-	DependsOn   []*Task
-	Healthcheck config.HealthCheckConfig
-	process     *exec.Cmd
-	Healthy     bool
-	logger      *utils.AppLogger
-	Terminated  bool
+	Name         string
+	BaseDir      string
+	Envs         []string
+	EnvInherit   bool
+	EnvAllowlist []string
+	Executable   string
+	Job          bool
+	Required     bool
+	OnExit       func(task *Task)
+	Args         []string
+	DependsOn    []Dependency
+	dependents   []*Task
+	Healthcheck  config.HealthCheckConfig
+	Liveness     *config.LivenessConfig
+	Restart      *config.RestartConfig
+	StopSignal   string
+	StopTimeout  time.Duration
+	StartTimeout time.Duration
+	process      *exec.Cmd
+	pgid         int
+	exited       chan struct{}
+	exitState    *os.ProcessState
+	startedAt    time.Time
+	logPattern   *regexp.Regexp
+	logMatched   atomic.Bool
+	liveRestart  atomic.Bool
+	terminated   atomic.Bool
+	lock         sync.Mutex
+	state        TaskState
+	started      bool
+	ready        bool
+	failed       bool
+	stopping     bool
+	stopped      chan struct{}
+	wake         chan struct{}
+	logger       *utils.AppLogger
+	restarts     int
+}
+
+// Dependency 是任務依賴的另一個任務，以及啟動前需要滿足的條件
+type Dependency struct {
+	Task      *Task
+	Condition string
 }
 
 type TaskProcess struct {
-	Name string `yaml:"name"`
-	Pid  int    `yaml:"pid"`
+	Name        string     `yaml:"name"`
+	Pid         int        `yaml:"pid"`
+	Pgid        int        `yaml:"pgid,omitempty"`
+	StartedAt   time.Time  `yaml:"started_at"`
+	StartTicks  uint64     `yaml:"start_ticks,omitempty"`
+	Restarts    int        `yaml:"restarts,omitempty"`
+	StopSignal  string     `yaml:"stop_signal,omitempty"`
+	StopTimeout string     `yaml:"stop_timeout,omitempty"`
+	ExitCode    *int       `yaml:"exit_code,omitempty"`
+	ExitedAt    *time.Time `yaml:"exited_at,omitempty"`
+}
+
+const (
+	ProcessRunning = "running"
+	ProcessExited  = "exited"
+	ProcessStale   = "stale"
+)
+
+// State 檢查記錄的程序是否仍在執行，並以程序啟動時間確認 PID 沒有被其他程序重複使用
+func (p *TaskProcess) State() string {
+	process, err := os.FindProcess(p.Pid)
+	if err != nil || !ProcessAlive(process, 0) {
+		return ProcessExited
+	}
+	if p.StartTicks > 0 {
+		if ticks, ok := processStartTicks(p.Pid); ok && ticks != p.StartTicks {
+			return ProcessStale
+		}
+	}
+	return ProcessRunning
 }
 
 type TaskProcessLog struct {
@@ -50,49 +110,206 @@ const (
 	healthCheckInterval       = 1 * time.Second
 	healthCheckTries          = 5
 	healthCheckStartDelay     = 1 * time.Second
+	restartDefaultBackoff     = 1 * time.Second
+	restartDefaultMaxBackoff  = 30 * time.Second
+	outputDrainTimeout        = 500 * time.Millisecond
+	livenessDefaultInterval   = 10 * time.Second
+	livenessDefaultTries      = 3
 )
 
 var TaskProcesses = TaskProcessLog{}
 
+var taskProcessesLock sync.Mutex
+
 func CreateTask(config config.TaskConfig) (*Task, error) {
 	var task = Task{
-		Name:        config.Name,
-		BaseDir:     config.BaseDir,
-		Envs:        config.Envs,
-		Executable:  config.Executable,
-		Args:        config.Args,
-		Healthcheck: config.Healthcheck,
+		Name:         config.Name,
+		BaseDir:      config.BaseDir,
+		Envs:         config.Envs,
+		EnvInherit:   config.EnvInherit == nil || *config.EnvInherit,
+		EnvAllowlist: config.EnvAllowlist,
+		Executable:   config.Executable,
+		Job:          config.IsJob(),
+		Required:     config.Required == nil || *config.Required,
+		Args:         config.Args,
+		Healthcheck:  config.Healthcheck,
+		Liveness:     config.Liveness,
+		Restart:      config.Restart,
+		StopSignal:   config.StopSignal,
+		StopTimeout:  ParseStopTimeout(config.StopTimeout),
+		StartTimeout: parseStartTimeout(config.StartTimeout),
+		stopped:      make(chan struct{}),
+		wake:         make(chan struct{}, 1),
+	}
+	if config.Healthcheck.Log != nil {
+		pattern, err := regexp.Compile(config.Healthcheck.Log.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("task %s healthcheck.log.pattern: %v", config.Name, err)
+		}
+		task.logPattern = pattern
 	}
 	return &task, nil
 }
 
-func (t *Task) AppendDependencies(dependency *Task) {
-	t.DependsOn = append(t.DependsOn, dependency)
+// parseStartTimeout 解析 start_timeout，未設定時為 0，表示不限制啟動時間
+func parseStartTimeout(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+func (t *Task) AppendDependencies(dependency *Task, condition string) {
+	t.DependsOn = append(t.DependsOn, Dependency{Task: dependency, Condition: condition})
+	dependency.dependents = append(dependency.dependents, t)
 }
 
 func (t *Task) Start(wg *sync.WaitGroup) {
+	defer wg.Done()
 	TaskSpinner.RegisterSpinner(t.Name, t.Name+"|", "Waiting")
-	t.logger = utils.NewAppLogger(t.Name, utils.Color.GetRandomColorCode())
+	t.logger = utils.NewAppLogger(t.Name, utils.Color.GetColorCode(t.Name))
+
+	t.setState(StateWaiting)
+	if ready, failed := t.waitDependencies(); !ready {
+		if failed != nil {
+			var message = fmt.Sprintf("Dependency %s failed", failed.Name)
+			t.logger.Warn(message)
+			if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+				spinner.ErrorWithMessage(message)
+			}
+			t.lock.Lock()
+			t.failed = true
+			t.lock.Unlock()
+			t.setState(StateStopped)
+			if t.OnExit != nil {
+				t.OnExit(t)
+			}
+			return
+		}
+		t.setState(StateStopped)
+		return
+	}
+
 	for {
-		check, terminated := t.checkDependencies()
-		if check {
-			break
-		}
-		if terminated {
-			// dependency terminated
-			t.Terminated = true
-			t.terminate(wg)
+		t.setState(StateStarting)
+		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+			spinner.UpdateMessage("Launching")
+		}
+
+		t.terminated.Store(false)
+		if !t.runCommand() {
+			t.setState(StateStopped)
+			return
+		}
+		t.logTaskProcess()
+
+		// a job is done when it exits, only services are health checked
+		var healthy, healthcheckMessage = true, ""
+		if t.Job {
+			if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+				spinner.UpdateMessage("Running")
+			}
+		} else {
+			t.setState(StateProbing)
+			if healthy, healthcheckMessage = t.waitHealthy(); healthy {
+				t.setState(StateHealthy)
+				if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+					spinner.CompleteWithMessage("Done" + "|" + healthcheckMessage)
+				}
+				if app.DetachMode && !t.awaitedToComplete() {
+					return
+				}
+				if t.Liveness != nil {
+					go t.monitorLiveness(t.exited)
+				}
+			} else if !t.isStopping() {
+				t.setState(StateUnhealthy)
+				t.terminate()
+			}
+		}
+
+		<-t.exited
+		var state = t.lastExit()
+		t.logTaskExit(state)
+		// a process killed by a signal is logged too, unless terminate already logged how it was stopped
+		if !t.Job && state != nil && (state.Exited() || !t.terminated.Load()) {
+			if state.Success() {
+				t.logger.Log("Completed")
+			} else {
+				t.logger.Warn(fmt.Sprintf("Exited with code %d", exitCode(state)))
+			}
+		}
+
+		if t.isStopping() {
+			t.setState(StateStopped)
+			return
+		}
+
+		if !t.shouldRestart(state, healthy) {
+			if t.Job {
+				t.logJobResult(state)
+			}
+			if !healthy {
+				if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+					spinner.ErrorWithMessage(healthcheckMessage)
+				}
+				t.lock.Lock()
+				t.failed = true
+				t.lock.Unlock()
+			}
+			t.setState(StateExited)
+			if t.OnExit != nil {
+				t.OnExit(t)
+			}
+			return
+		}
+
+		t.setState(StateStarting)
+		t.restarts++
+		var delay = t.restartDelay()
+		var restartMessage = fmt.Sprintf("Restarting in %s (%d)", delay, t.restarts)
+		if state != nil {
+			restartMessage = fmt.Sprintf("Exited with code %d, restarting in %s (%d)", exitCode(state), delay, t.restarts)
+		}
+		t.logger.Warn(restartMessage)
+		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+			spinner.UpdateMessage(restartMessage)
+		}
+		select {
+		case <-time.After(delay):
+		case <-t.stopped:
+			t.setState(StateStopped)
 			return
 		}
-		time.Sleep(1 * time.Second)
 	}
+}
 
-	if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
-		spinner.UpdateMessage("Launching")
+// waitDependencies 等待依賴的條件都滿足，依賴的狀態改變時會立即被喚醒重新檢查。
+// 被停止時回傳 false，依賴已無法滿足條件時一併回傳該依賴
+func (t *Task) waitDependencies() (bool, *Task) {
+	for {
+		ready, failed := t.checkDependencies()
+		if ready || failed != nil {
+			return ready, failed
+		}
+		select {
+		case <-t.wake:
+		case <-t.stopped:
+			return false, nil
+		}
 	}
+}
 
-	t.runCommand()
-	t.logTaskProcess()
+// waitHealthy 在任務啟動後執行健康檢查，直到成功或超過嘗試次數
+func (t *Task) waitHealthy() (bool, string) {
+	if !t.isHealthCheckConfigured() {
+		// nothing to wait for, dependents may start right away
+		return true, "Started"
+	}
 
 	var interval = healthCheckInterval
 	var tries = healthCheckTries
@@ -111,194 +328,282 @@ func (t *Task) Start(wg *sync.WaitGroup) {
 		}
 	}
 
-	time.Sleep(startDelay)
+	select {
+	case <-time.After(startDelay):
+	case <-t.stopped:
+		return false, "Stopped"
+	}
 
 	var ticker = time.NewTicker(interval)
 	failures := 0
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.stopped:
+			return false, "Stopped"
+		}
 		if check := t.doHealthCheck(); check {
-			t.Healthy = true
-			break
+			var healthcheckMessage = fmt.Sprintf("Health check %d/%d success", failures+1, tries)
+			t.logger.Success(healthcheckMessage)
+			return true, healthcheckMessage
 		}
 		failures++
 		var healthcheckMessage = fmt.Sprintf("Health check %d/%d fail", failures, tries)
-		if t.isHealthCheckConfigured() {
-			t.logger.Warn(healthcheckMessage)
-			if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
-				var previousMsg = spinner.GetMessage()
-				spinner.UpdateMessage(previousMsg + "|" + healthcheckMessage)
-			}
+		t.logger.Warn(healthcheckMessage)
+		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+			var previousMsg = spinner.GetMessage()
+			spinner.UpdateMessage(previousMsg + "|" + healthcheckMessage)
 		}
 
 		if failures >= tries {
-			t.Healthy = false
-			break
+			return false, healthcheckMessage
 		}
 	}
+}
 
-	if t.Healthy {
-		var healthcheckMessage = fmt.Sprintf("Health check %d/%d success", failures+1, tries)
-		if t.isHealthCheckConfigured() {
-			t.logger.Success(healthcheckMessage)
-		}
-		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
-			spinner.CompleteWithMessage("Done" + "|" + healthcheckMessage)
-		}
-	} else {
-		var healthcheckMessage = fmt.Sprintf("Health check %d/%d fail", failures, tries)
-		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
-			spinner.ErrorWithMessage(healthcheckMessage)
-		}
-		t.terminate(wg)
-		t.Terminated = true
+// shouldRestart 依照 restart 策略判斷結束的任務是否需要重新啟動
+func (t *Task) shouldRestart(state *os.ProcessState, healthy bool) bool {
+	// a task stopped by its liveness check is restarted regardless of the policy
+	var liveness = t.liveRestart.Swap(false)
+	if t.Restart == nil {
+		return liveness
 	}
-
-	if app.DetachMode {
-		wg.Done()
-		return
+	if t.Restart.MaxRetries > 0 && t.restarts >= t.Restart.MaxRetries {
+		t.logger.Warn(fmt.Sprintf("Restart limit reached (%d)", t.Restart.MaxRetries))
+		return false
 	}
-
-	if state, _ := t.process.Process.Wait(); state != nil {
-		if state.Exited() {
-			t.logger.Log("Completed")
-		}
+	if liveness {
+		return true
+	}
+	var failed = !healthy || state == nil || !state.Success()
+	switch t.Restart.Policy {
+	case config.RestartAlways:
+		return true
+	case config.RestartUnlessStopped:
+		// only a task stopped by Stop stays stopped, a crash such as SIGSEGV is restarted
+		return !t.isStopping()
+	case config.RestartOnFailure:
+		return failed
+	default:
+		return false
 	}
-
-	wg.Done()
-
 }
 
-func (t *Task) checkDependencies() (bool, bool) {
-	var check = true
-	for _, dependency := range t.DependsOn {
-		if dependency.Terminated {
-			return false, true
-		}
-		check = dependency.Healthy && check
+// restartDelay 回傳以指數方式成長的重新啟動等待時間
+func (t *Task) restartDelay() time.Duration {
+	var delay = restartDefaultBackoff
+	var maxDelay = restartDefaultMaxBackoff
+	if t.Restart != nil && t.Restart.Backoff != "" {
+		delay, _ = time.ParseDuration(t.Restart.Backoff)
+	}
+	if t.Restart != nil && t.Restart.MaxBackoff != "" {
+		maxDelay, _ = time.ParseDuration(t.Restart.MaxBackoff)
+	}
+	for i := 1; i < t.restarts && delay < maxDelay; i++ {
+		delay *= 2
 	}
-	return check, false
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
 }
 
-func (t *Task) isHealthCheckConfigured() bool {
-	return !(t.Healthcheck.HTTP == nil && t.Healthcheck.Command == nil)
+// Stop 停止任務並取消後續的重新啟動，會等待程序結束後才返回
+func (t *Task) Stop() {
+	t.markStopping()
+	t.terminate()
 }
 
-func (t *Task) doHealthCheck() bool {
+// Kill 不等待 stop_timeout，立即以 SIGKILL 結束任務的 process group，並取消後續的重新啟動
+func (t *Task) Kill() {
+	t.markStopping()
 
-	if !t.isHealthCheckConfigured() {
-		return true
+	t.lock.Lock()
+	var process = t.process
+	var pgid = t.pgid
+	var exited = t.exited
+	t.lock.Unlock()
+	if process == nil || process.Process == nil {
+		return
 	}
+	select {
+	case <-exited:
+		if !groupAlive(pgid) {
+			return
+		}
+	default:
+	}
+	t.terminated.Store(true)
+	if err := killProcess(process.Process, pgid); err != nil {
+		t.logger.Warn(fmt.Sprintf("Error killing process: %v", err))
+		return
+	}
+	t.logger.Log(fmt.Sprintf("Killed PID: %d", process.Process.Pid))
+}
 
-	var timeout = healthCheckDefaultTimeout
-
-	if t.Healthcheck.Frequency != nil && t.Healthcheck.Frequency.Timeout != "" {
-		timeout, _ = time.ParseDuration(t.Healthcheck.Frequency.Timeout)
+func (t *Task) markStopping() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.stopping {
+		t.stopping = true
+		close(t.stopped)
 	}
+}
 
-	if t.Healthcheck.HTTP != nil {
-		client := &http.Client{
-			Timeout: timeout,
+// monitorLiveness 在任務就緒後持續執行 liveness 檢查，直到程序結束
+func (t *Task) monitorLiveness(exited <-chan struct{}) {
+	var liveness = t.Liveness
+	var interval = livenessDefaultInterval
+	var tries = livenessDefaultTries
+	var delay time.Duration
+	if freq := liveness.Frequency; freq != nil {
+		if freq.Interval != "" {
+			interval, _ = time.ParseDuration(freq.Interval)
 		}
-		resp, err := client.Get(t.Healthcheck.HTTP.URL)
-		if err != nil {
-			return false
+		if freq.Tries > 0 {
+			tries = freq.Tries
 		}
-
-		if resp.StatusCode < 200 && resp.StatusCode >= 300 {
-			return false
+		if freq.Delay != "" {
+			delay, _ = time.ParseDuration(freq.Delay)
 		}
+	}
 
-		if t.Healthcheck.HTTP.Expect != nil {
-			if t.Healthcheck.HTTP.Expect.Json != nil {
-				if t.Healthcheck.HTTP.Expect.Json.Jsonpath == "" {
-					t.logger.Debug(fmt.Sprintf("jsonpath not set"))
-					return false
-				}
-
-				var contentType = resp.Header.Get("Content-Type")
-				if !strings.Contains(contentType, "application/json") {
-					t.logger.Debug(fmt.Sprintf("Health check contenttype: %s", contentType))
-					return false
-				}
-
-				bodyBytes, err := io.ReadAll(resp.Body)
-				_ = resp.Body.Close()
-
-				if err != nil {
-					t.logger.Debug(fmt.Sprintf("Health check body error: %v", err))
-					return false
-				}
-
-				var jsonResp interface{}
-				err = json.Unmarshal(bodyBytes, &jsonResp)
-				if err != nil {
-					return false
-				}
-
-				checkValue, err := jsonpath.JsonPathLookup(jsonResp, t.Healthcheck.HTTP.Expect.Json.Jsonpath)
+	select {
+	case <-exited:
+		return
+	case <-t.stopped:
+		return
+	case <-time.After(delay):
+	}
 
-				if err != nil {
-					return false
-				}
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	var healthy = true
+	failures := 0
+	for {
+		select {
+		case <-exited:
+			return
+		case <-t.stopped:
+			return
+		case <-ticker.C:
+		}
 
-				if t.Healthcheck.HTTP.Expect.Json.Value == "" {
-					return true
-				} else {
-					return checkValue == t.Healthcheck.HTTP.Expect.Json.Value
-				}
+		if t.runProbes(&liveness.HealthCheckConfig) {
+			failures = 0
+			if !healthy && t.transition(StateUnhealthy, StateHealthy) {
+				healthy = true
+				t.logger.Success("Liveness check success, task is healthy again")
 			}
+			continue
+		}
+		if !healthy {
+			continue
+		}
 
-			if t.Healthcheck.HTTP.Expect.Plain != nil {
+		failures++
+		t.logger.Warn(fmt.Sprintf("Liveness check %d/%d fail", failures, tries))
+		if failures < tries {
+			continue
+		}
 
-				if t.Healthcheck.HTTP.Expect.Plain.Contains == "" {
-					return false
-				}
+		if !t.transition(StateHealthy, StateUnhealthy) {
+			// the process exited or is being stopped
+			return
+		}
+		healthy = false
+		t.logger.Warn(fmt.Sprintf("Task is unhealthy after %d failed liveness checks", failures))
+		switch liveness.Action {
+		case config.LivenessActionRestart:
+			t.liveRestart.Store(true)
+			t.terminate()
+			return
+		case config.LivenessActionStopDependents:
+			t.stopDependents()
+		}
+	}
+}
 
-				bodyBytes, err := io.ReadAll(resp.Body)
-				_ = resp.Body.Close()
-				if err != nil {
-					return false
-				}
-				bodyString := string(bodyBytes)
+// stopDependents 停止依賴此任務的任務，依賴它們的任務會先被停止
+func (t *Task) stopDependents() {
+	var wg sync.WaitGroup
+	for _, dependent := range t.dependents {
+		if dependent.isStopping() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dependent.stopDependents()
+			t.logger.Warn(fmt.Sprintf("Stopping %s, which depends on this task", dependent.Name))
+			dependent.Stop()
+		}()
+	}
+	wg.Wait()
+}
 
-				return strings.Contains(bodyString, t.Healthcheck.HTTP.Expect.Plain.Contains)
+func (t *Task) isStopping() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.stopping
+}
 
-			}
+// checkDependencies 回傳依賴的條件是否都已滿足，以及已無法滿足條件的依賴
+func (t *Task) checkDependencies() (bool, *Task) {
+	var check = true
+	for _, dependency := range t.DependsOn {
+		var satisfied, failed, _ = dependency.check()
+		if failed {
+			return false, dependency.Task
 		}
+		check = satisfied && check
+	}
+	return check, nil
+}
 
+// check 回傳依賴的條件是否已滿足、是否已無法滿足，以及依賴目前的狀態
+func (d Dependency) check() (satisfied bool, failed bool, state TaskState) {
+	var started, ready, success bool
+	state, started, ready, success = d.Task.dependencyState()
+	switch d.Condition {
+	case config.DependencyStarted:
+		satisfied = started
+	case config.DependencyCompletedSuccessfully:
+		if state == StateExited && !success {
+			return false, true, state
+		}
+		satisfied = state == StateExited
+	default:
+		// a dependency that passed its health check and exited afterwards still satisfied the condition
+		satisfied = ready
 	}
+	// a task that reached a final state without satisfying the condition never will
+	return satisfied, !satisfied && state.Final(), state
+}
 
-	if t.Healthcheck.Command != nil {
-		if len(t.Healthcheck.Command.Scripts) > 0 {
-			var cmd = t.Healthcheck.Command.Scripts[0]
-			var args = t.Healthcheck.Command.Scripts[1:]
-			ctx, cancel := context.WithTimeout(context.Background(), timeout)
-			var process = exec.CommandContext(ctx, cmd, args...)
-			defer cancel()
+// PendingDependencies 列出條件尚未滿足的依賴，格式為 name (condition, state)
+func (t *Task) PendingDependencies() []string {
+	var pending []string
+	for _, dependency := range t.DependsOn {
+		if satisfied, _, state := dependency.check(); !satisfied {
+			pending = append(pending, fmt.Sprintf("%s (%s, %s)", dependency.Task.Name, dependency.Condition, state))
+		}
+	}
+	return pending
+}
 
-			if err := process.Start(); err != nil {
-				return false
-			}
-			done := make(chan error, 1)
-			go func() {
-				done <- process.Wait()
-			}()
-			select {
-			case <-ctx.Done():
-				return false
-			case err := <-done:
-				if err != nil {
-					return false
-				}
+// awaitedToComplete 判斷是否有任務等待此任務成功結束
+func (t *Task) awaitedToComplete() bool {
+	for _, dependent := range t.dependents {
+		for _, dependency := range dependent.DependsOn {
+			if dependency.Task == t && dependency.Condition == config.DependencyCompletedSuccessfully {
+				return true
 			}
-		} else {
-			return false
 		}
 	}
-	return true
-
+	return false
 }
 
 func (t *Task) logTaskProcess() {
@@ -309,11 +614,91 @@ func (t *Task) logTaskProcess() {
 		return
 	}
 
-	var processLog = TaskProcess{
-		Name: t.Name,
-		Pid:  t.process.Process.Pid,
+	var pid = t.process.Process.Pid
+	var startTicks, _ = processStartTicks(pid)
+
+	t.updateTaskProcess(func(processLog *TaskProcess) {
+		processLog.Pid = pid
+		processLog.Pgid = t.pgid
+		processLog.StartedAt = time.Now()
+		processLog.StartTicks = startTicks
+		processLog.Restarts = t.restarts
+		processLog.StopSignal = t.StopSignal
+		processLog.StopTimeout = t.StopTimeout.String()
+		processLog.ExitCode = nil
+		processLog.ExitedAt = nil
+	})
+}
+
+// logJobResult 記錄 job 的結束代碼
+func (t *Task) logJobResult(state *os.ProcessState) {
+	if state != nil && state.Success() {
+		t.logger.Success("Job succeeded")
+		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+			spinner.CompleteWithMessage("Job succeeded")
+		}
+		return
+	}
+	var message = "Job failed"
+	if state != nil {
+		message = fmt.Sprintf("Job failed with exit code %d", exitCode(state))
+	}
+	t.logger.Warn(message)
+	if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+		spinner.ErrorWithMessage(message)
+	}
+}
+
+// Completed 判斷任務是否已結束且不會再重新啟動
+func (t *Task) Completed() bool {
+	return t.State() == StateExited
+}
+
+// ExitCode 回傳任務最後一次結束的代碼，尚未結束時第二個回傳值為 false
+func (t *Task) ExitCode() (int, bool) {
+	var state = t.lastExit()
+	if state == nil {
+		return 0, false
+	}
+	return exitCode(state), true
+}
+
+// lastExit 回傳最後一次結束的程序狀態
+func (t *Task) lastExit() *os.ProcessState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.exitState
+}
+
+// logTaskExit 記錄任務程序的結束代碼
+func (t *Task) logTaskExit(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+	var code = exitCode(state)
+	var exitedAt = time.Now()
+	t.updateTaskProcess(func(processLog *TaskProcess) {
+		processLog.ExitCode = &code
+		processLog.ExitedAt = &exitedAt
+	})
+}
+
+func (t *Task) updateTaskProcess(update func(processLog *TaskProcess)) {
+	taskProcessesLock.Lock()
+	defer taskProcessesLock.Unlock()
+
+	var processLog *TaskProcess
+	for _, logged := range TaskProcesses.Tasks {
+		if logged.Name == t.Name {
+			processLog = logged
+		}
+	}
+	if processLog == nil {
+		processLog = &TaskProcess{Name: t.Name}
+		TaskProcesses.Tasks = append(TaskProcesses.Tasks, processLog)
 	}
-	TaskProcesses.Tasks = append(TaskProcesses.Tasks, &processLog)
+	update(processLog)
+
 	data, err := yaml.Marshal(&TaskProcesses)
 	if err != nil {
 		t.logger.Error(err)
@@ -328,16 +713,72 @@ func (t *Task) logTaskProcess() {
 	}
 }
 
-func (t *Task) runCommand() {
+// RemoveTaskProcessLog 移除記錄任務 PID 的檔案
+func RemoveTaskProcessLog() {
+	if dir, err := os.Getwd(); err == nil {
+		var pidFile = filepath.Join(dir, PidFile)
+		if err = os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
+			utils.SharedAppLogger.Error(err)
+		}
+	}
+}
+
+// environ 合併父程序的環境變數與任務的 envs，任務的設定優先。
+// 設定 env_allowlist 時只繼承符合的變數，env_inherit 為 false 且沒有 env_allowlist 時不繼承任何變數。
+func (t *Task) environ() []string {
+	var inherited []string
+	for _, env := range os.Environ() {
+		if t.inheritEnv(config.EnvKey(env)) {
+			inherited = append(inherited, env)
+		}
+	}
+	var environ = config.MergeEnvs(inherited, t.Envs)
+	if environ == nil {
+		// a nil Env makes exec.Cmd fall back to the parent environment
+		return []string{}
+	}
+	return environ
+}
+
+func (t *Task) inheritEnv(key string) bool {
+	if len(t.EnvAllowlist) == 0 {
+		return t.EnvInherit
+	}
+	for _, pattern := range t.EnvAllowlist {
+		if runtime.GOOS == "windows" {
+			// environment variable names are case-insensitive on windows
+			pattern, key = strings.ToUpper(pattern), strings.ToUpper(key)
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand 啟動任務程序，任務已被停止時不會啟動並回傳 false
+func (t *Task) runCommand() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.stopping {
+		return false
+	}
+
+	t.logMatched.Store(false)
+	t.startedAt = time.Now()
 	t.process = exec.Command(t.Executable, t.Args...)
 	//log.Println(utils.Convertor.ToJson(t))
 	if t.BaseDir != "" {
 		t.process.Dir = t.BaseDir
 	}
-	t.process.Env = t.Envs
+	t.process.Env = t.environ()
+	setProcessGroup(t.process)
 	//t.process.Stderr = os.Stderr
 	//t.process.Stdout = os.Stdout
 
+	var outputFile string
+	var outputOffset int64
+	var outputDone = make(chan struct{})
 	if !app.DetachMode {
 		// front ground detach mode
 
@@ -349,11 +790,19 @@ func (t *Task) runCommand() {
 		if err != nil {
 			t.logger.Error(err)
 		}
+		var readers sync.WaitGroup
+		readers.Add(2)
+		go func() {
+			readers.Wait()
+			close(outputDone)
+		}()
 		go func() {
+			defer readers.Done()
 			scanner := bufio.NewScanner(stdoutPipe)
 			for scanner.Scan() {
 				line := scanner.Text()
 				t.logger.Info(line)
+				t.watchOutput(config.LogStreamStdout, line)
 			}
 			if err := stdoutPipe.Close(); err != nil {
 				if description := err.Error(); description == "close |0: file already closed" {
@@ -364,12 +813,14 @@ func (t *Task) runCommand() {
 			}
 		}()
 		go func() {
+			defer readers.Done()
 			scanner := bufio.NewScanner(stderrPipe)
 			for scanner.Scan() {
 				line := scanner.Text()
-				t.logger.Error(fmt.Errorf(line))
+				t.logger.Error(errors.New(line))
+				t.watchOutput(config.LogStreamStderr, line)
 			}
-			if err := stdoutPipe.Close(); err != nil {
+			if err := stderrPipe.Close(); err != nil {
 				if description := err.Error(); description == "close |0: file already closed" {
 					// task ended.
 					return
@@ -377,6 +828,22 @@ func (t *Task) runCommand() {
 				t.logger.Error(err)
 			}
 		}()
+	} else {
+		close(outputDone)
+		// detached tasks outlive task-compose, their output is appended to a file of its own,
+		// so that the log check only matches what the process wrote
+		output, err := os.OpenFile(utils.OutputFileName(t.Name, time.Now()), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.logger.Error(err)
+		} else {
+			defer output.Close()
+			if info, err := output.Stat(); err == nil {
+				outputOffset = info.Size()
+			}
+			outputFile = output.Name()
+			t.process.Stdout = output
+			t.process.Stderr = output
+		}
 	}
 
 	if err := t.process.Start(); err != nil {
@@ -385,15 +852,59 @@ func (t *Task) runCommand() {
 		}
 		utils.SharedAppLogger.Fatal(err)
 	}
+
+	t.pgid = processGroupID(t.process.Process.Pid)
+	t.started = true
+
+	var process = t.process
+	var exited = make(chan struct{})
+	t.exited = exited
+	go func() {
+		state, _ := process.Process.Wait()
+		// let the last lines of output be logged before the exit, unless a child process keeps the pipes open
+		select {
+		case <-outputDone:
+		case <-time.After(outputDrainTimeout):
+		}
+		t.lock.Lock()
+		t.exitState = state
+		t.lock.Unlock()
+		close(exited)
+	}()
+
+	if outputFile != "" && t.Healthcheck.Log != nil {
+		go t.tailOutput(outputFile, outputOffset, exited)
+	}
+	return true
 }
 
-func (t *Task) terminate(wg *sync.WaitGroup) {
-	if t.process != nil && t.process.Process != nil {
-		if err := t.process.Process.Kill(); err != nil {
+func (t *Task) terminate() {
+	t.lock.Lock()
+	var process = t.process
+	var pgid = t.pgid
+	var exited = t.exited
+	t.lock.Unlock()
+
+	if process != nil && process.Process != nil {
+		t.terminated.Store(true)
+		alive := func() bool {
+			select {
+			case <-exited:
+				return groupAlive(pgid)
+			default:
+				return true
+			}
+		}
+		message, err := StopProcess(process.Process, pgid, t.StopSignal, t.StopTimeout, alive)
+		if err != nil {
 			if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
 				spinner.ErrorWithMessagef("Error killing process: %s", err.Error())
 			}
+			return
+		}
+		t.logger.Log(message)
+		if spinner, ok := TaskSpinner.GetSpinner(t.Name); ok {
+			spinner.UpdateMessage(message)
 		}
 	}
-	wg.Done()
 }
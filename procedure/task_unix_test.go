@@ -0,0 +1,189 @@
+//go:build !windows
+
+package procedure
+
+import (
+	"github.com/vulcanshen-tpi/task-compose/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTasks 在暫存目錄中建立並啟動任務，dependsOn 以 任務名稱 -> 依賴與條件 描述
+func startTasks(t *testing.T, configs []config.TaskConfig, dependsOn map[string][]Dependency) (map[string]*Task, <-chan struct{}) {
+	t.Helper()
+	// tasks write their logs and pid file to the working directory
+	t.Chdir(t.TempDir())
+
+	var tasks = make(map[string]*Task)
+	for _, taskConfig := range configs {
+		task, err := CreateTask(taskConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks[taskConfig.Name] = task
+	}
+	for name, dependencies := range dependsOn {
+		for _, dependency := range dependencies {
+			tasks[name].AppendDependencies(tasks[dependency.Task.Name], dependency.Condition)
+		}
+	}
+
+	var waitGroup = &sync.WaitGroup{}
+	waitGroup.Add(len(tasks))
+	for _, task := range tasks {
+		go task.Start(waitGroup)
+	}
+	var done = make(chan struct{})
+	go func() {
+		waitGroup.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		for _, task := range tasks {
+			task.Stop()
+		}
+		<-done
+	})
+	return tasks, done
+}
+
+// dependsOn 以名稱建立 startTasks 使用的依賴，Task 只用來攜帶名稱
+func dependsOn(name string, condition string) Dependency {
+	return Dependency{Task: &Task{Name: name}, Condition: condition}
+}
+
+func waitForState(t *testing.T, task *Task, state TaskState) {
+	t.Helper()
+	var deadline = time.Now().Add(10 * time.Second)
+	for task.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s is %s, want %s", task.Name, task.State(), state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(15 * time.Second):
+		t.Fatal("tasks did not return after they were stopped")
+	}
+}
+
+func TestStartDependencyChain(t *testing.T) {
+	var tasks, done = startTasks(t, []config.TaskConfig{
+		{Name: "migrate", Type: config.TaskTypeJob, Executable: "sleep", Args: []string{"0.3"}},
+		{Name: "api", Executable: "sleep", Args: []string{"30"}, StopTimeout: "2s"},
+		{Name: "worker", Executable: "sleep", Args: []string{"30"}, StopTimeout: "2s"},
+	}, map[string][]Dependency{
+		"api":    {dependsOn("migrate", config.DependencyCompletedSuccessfully)},
+		"worker": {dependsOn("api", config.DependencyStarted), dependsOn("migrate", config.DependencyCompletedSuccessfully)},
+	})
+	var migrate, api, worker = tasks["migrate"], tasks["api"], tasks["worker"]
+
+	// services without a healthcheck are healthy once launched
+	waitForState(t, worker, StateHealthy)
+	waitForState(t, api, StateHealthy)
+
+	if !migrate.Completed() {
+		t.Fatalf("migrate is %s, want exited before its dependents started", migrate.State())
+	}
+	if code, exited := migrate.ExitCode(); !exited || code != 0 {
+		t.Errorf("migrate exit code = %d (exited %t), want 0", code, exited)
+	}
+	migrate.lock.Lock()
+	var migrateStarted = migrate.startedAt
+	migrate.lock.Unlock()
+	api.lock.Lock()
+	var apiStarted = api.startedAt
+	api.lock.Unlock()
+	worker.lock.Lock()
+	var workerStarted = worker.startedAt
+	worker.lock.Unlock()
+	if apiStarted.Sub(migrateStarted) < 300*time.Millisecond {
+		t.Errorf("api started %s after migrate, before migrate completed", apiStarted.Sub(migrateStarted))
+	}
+	if workerStarted.Before(apiStarted) {
+		t.Errorf("worker started before api")
+	}
+
+	// stop in reverse dependency order, the way up shuts down
+	worker.Stop()
+	api.Stop()
+	migrate.Stop()
+	waitDone(t, done)
+
+	for _, task := range []*Task{api, worker} {
+		if task.State() != StateStopped {
+			t.Errorf("%s is %s after Stop, want %s", task.Name, task.State(), StateStopped)
+		}
+		if task.Failed() {
+			t.Errorf("%s failed", task.Name)
+		}
+	}
+	// a finished job keeps its final state
+	if migrate.State() != StateExited {
+		t.Errorf("migrate is %s after Stop, want %s", migrate.State(), StateExited)
+	}
+}
+
+func TestStartFailedDependency(t *testing.T) {
+	var tasks, done = startTasks(t, []config.TaskConfig{
+		{Name: "migrate", Type: config.TaskTypeJob, Executable: "false"},
+		{Name: "api", Executable: "sleep", Args: []string{"30"}},
+	}, map[string][]Dependency{
+		"api": {dependsOn("migrate", config.DependencyCompletedSuccessfully)},
+	})
+	waitDone(t, done)
+
+	var api = tasks["api"]
+	if !api.Failed() || api.State() != StateStopped {
+		t.Errorf("api is %s (failed %t), want stopped and failed", api.State(), api.Failed())
+	}
+	if _, _, started, _ := api.dependencyState(); started {
+		t.Error("api was started although its dependency failed")
+	}
+	if code, exited := tasks["migrate"].ExitCode(); !exited || code != 1 {
+		t.Errorf("migrate exit code = %d (exited %t), want 1", code, exited)
+	}
+}
+
+func TestStopWhileWaiting(t *testing.T) {
+	var tasks, done = startTasks(t, []config.TaskConfig{
+		{
+			Name:       "db",
+			Executable: "sleep",
+			Args:       []string{"30"},
+			Healthcheck: config.HealthCheckConfig{
+				Command:   &config.CommandCheck{Scripts: []string{"false"}},
+				Frequency: &config.CheckFrequency{Delay: "0s", Interval: "50ms", Tries: 1000},
+			},
+			StopTimeout: "2s",
+		},
+		{Name: "api", Executable: "sleep", Args: []string{"30"}},
+	}, map[string][]Dependency{
+		"api": {dependsOn("db", config.DependencyHealthy)},
+	})
+	var db, api = tasks["db"], tasks["api"]
+
+	waitForState(t, db, StateProbing)
+	waitForState(t, api, StateWaiting)
+	if pending := api.PendingDependencies(); len(pending) != 1 || pending[0] != "db (healthy, probing)" {
+		t.Errorf("api pending dependencies = %q", pending)
+	}
+
+	api.Stop()
+	waitForState(t, api, StateStopped)
+	if api.Failed() {
+		t.Error("api failed although it was stopped")
+	}
+
+	db.Stop()
+	waitDone(t, done)
+	if db.State() != StateStopped {
+		t.Errorf("db is %s after Stop, want %s", db.State(), StateStopped)
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
-import "math/rand"
+import (
+	"hash/fnv"
+)
 
 type color struct{}
 
@@ -13,15 +15,22 @@ const DebugColor = 39
 const SuccessColor = 82
 const LogColor = 111
 
-func (c *color) GetRandomColorCode() int {
-	var colorCode = rand.Intn(256)
-	for colorCode == ErrorColor ||
+func isReservedColor(colorCode int) bool {
+	return colorCode == ErrorColor ||
 		colorCode == AppColor ||
 		colorCode == WarningColor ||
 		colorCode == DebugColor ||
 		colorCode == SuccessColor ||
-		colorCode == LogColor {
-		colorCode = rand.Intn(256)
+		colorCode == LogColor
+}
+
+// GetColorCode 依照名稱產生固定的顏色，讓同一個任務在每次執行時顏色一致
+func (c *color) GetColorCode(name string) int {
+	var hash = fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	var colorCode = int(hash.Sum32() % 256)
+	for isReservedColor(colorCode) {
+		colorCode = (colorCode + 1) % 256
 	}
 	return colorCode
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/vulcanshen-tpi/task-compose/app"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -23,34 +24,72 @@ var SharedAppLogger = AppLogger{
 }
 
 func makeDir() {
-	err := os.MkdirAll("logs", 0755) // 0755 是目錄的權限
+	err := os.MkdirAll(LogDir, 0755) // 0755 是目錄的權限
 	if err != nil {
 		SharedAppLogger.console.Fatal(err)
 		return
 	}
 }
 
+const (
+	LogDir        = "logs"
+	LogDateLayout = "2006-01-02"
+	// LogTimeLayout 為寫入 log 檔案時每一行開頭的時間格式
+	LogTimeLayout = "2006/01/02 15:04:05.000000"
+)
+
+// LogFileName 回傳任務在指定日期的 log 檔案路徑
+func LogFileName(prefix string, day time.Time) string {
+	return filepath.Join(LogDir, fmt.Sprintf("%s-%s.log", prefix, day.Format(LogDateLayout)))
+}
+
+// OutputFileName 回傳 detach 模式下任務程序輸出的檔案路徑，與 task-compose 寫入的 log 分開，內容沒有時間戳記
+func OutputFileName(prefix string, day time.Time) string {
+	return filepath.Join(LogDir, fmt.Sprintf("%s-%s.output.log", prefix, day.Format(LogDateLayout)))
+}
+
+// dailyFileWriter 依照寫入當天的日期切換 log 檔案
+type dailyFileWriter struct {
+	prefix string
+	day    string
+	file   *os.File
+}
+
+func (w *dailyFileWriter) Write(p []byte) (int, error) {
+	var now = time.Now()
+	if today := now.Format(LogDateLayout); w.file == nil || today != w.day {
+		file, err := os.OpenFile(LogFileName(w.prefix, now), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, err
+		}
+		if w.file != nil {
+			_ = w.file.Close()
+		}
+		w.file = file
+		w.day = today
+	}
+	return w.file.Write(p)
+}
+
 func NewAppLogger(prefix string, color int) *AppLogger {
 	consoleLogger := log.New(os.Stdout, "", 0)
 	makeDir()
-	today := time.Now().Format("2006-01-02")
-	fileName := fmt.Sprintf("logs/%s-%s.log", prefix, today)
 
-	var appLogger = &AppLogger{
+	return &AppLogger{
 		prefix:  prefix,
 		color:   color,
 		console: consoleLogger,
+		file:    log.New(&dailyFileWriter{prefix: prefix}, "", log.Ldate|log.Ltime|log.Lmicroseconds),
 	}
+}
 
-	if file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
-		fileLogger := log.New(file, "", 0)
-		appLogger.file = fileLogger
-	}
-	return appLogger
+// ColoredPrefix 回傳 console 輸出時使用的 "name|" 前綴
+func ColoredPrefix(prefix string, color int) string {
+	return Convertor.Colored(fmt.Sprintf("%s|", prefix), color)
 }
 
 func (apl *AppLogger) getPrefix() string {
-	return Convertor.Colored(fmt.Sprintf("%s|", apl.prefix), apl.color)
+	return ColoredPrefix(apl.prefix, apl.color)
 }
 
 func (apl *AppLogger) Info(message ...string) {